@@ -0,0 +1,104 @@
+package karmabot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/munge"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// homeHistoryLimit is the amount of received karma operations
+// that the App Home tab lists.
+const homeHistoryLimit = 5
+
+// homeLeaderboardLimit is the amount of users on the App Home
+// tab's leaderboard.
+const homeLeaderboardLimit = 10
+
+// handleAppHomeOpenedEvent publishes a user's karma dashboard
+// whenever they open karmabot's Home tab, so that it is always up
+// to date.
+func (b *Bot) handleAppHomeOpenedEvent(ctx context.Context, ev *slackevents.AppHomeOpenedEvent) {
+	if ev.Tab != "home" {
+		return
+	}
+
+	blocks, err := b.homeBlocks(ctx, ev.User)
+	if err != nil {
+		b.Config.Log.Err(err).KV("user", ev.User).Error("could not render the home tab")
+		return
+	}
+
+	err = b.Config.Slack.PublishView(ev.User, slack.HomeTabViewRequest{
+		Type:   slack.VTHomeTab,
+		Blocks: slack.Blocks{BlockSet: blocks},
+	})
+	if err != nil {
+		b.Config.Log.Err(err).KV("user", ev.User).Error("could not publish the home tab")
+	}
+}
+
+// homeBlocks renders a user's karma dashboard: their points and
+// rank, the karma operations they have received recently and the
+// leaderboard.
+func (b *Bot) homeBlocks(ctx context.Context, id string) ([]slack.Block, error) {
+	var (
+		filter = b.scoreFilter()
+		l      = b.locale("")
+	)
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, l.Sprintf("Your karma"), false, false)),
+	}
+
+	user, err := b.Config.DB.GetUser(ctx, id, filter)
+	switch {
+	case err == database.ErrNoSuchUser:
+		blocks = append(blocks, textBlock(l.Sprintf("you have not received any karma yet.")))
+	case err != nil:
+		return nil, err
+	default:
+		rank, err := b.Config.DB.GetRank(ctx, id, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+			markdown(l.Sprintf("*Points*\n%d", user.Points)),
+			markdown(l.Sprintf("*Rank*\n#%d", rank)),
+		}, nil))
+
+		history, err := b.Config.DB.GetHistory(ctx, id, homeHistoryLimit, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, textBlock(l.Sprintf("*recently received*")))
+		for _, record := range history {
+			operation := l.Sprintf("%+d from %s %s", record.Points.Points, munge.Munge(record.From), l.Time(record.Timestamp))
+			if record.Reason != "" {
+				operation += l.Sprintf(" for %s", l.Reason(record.Reason))
+			}
+
+			blocks = append(blocks, slack.NewContextBlock("", markdown(operation)))
+		}
+	}
+
+	leaderboard, err := b.Config.DB.GetLeaderboard(ctx, homeLeaderboardLimit, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := b.Config.UI.GetURL(fmt.Sprintf("/leaderboard/%d", homeLeaderboardLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	blocks = append(blocks, slack.NewDividerBlock())
+	blocks = append(blocks, leaderboardBlocks(l, l.Sprintf("*top %d %s*", homeLeaderboardLimit, l.Sprintf("leaderboard")), url, leaderboard, 0)...)
+
+	return blocks, nil
+}
@@ -0,0 +1,47 @@
+package karmabot
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestAppHome(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New()})
+	db.InsertPoints(context.Background(), &database.Points{From: "U1111", To: "U1234", Points: 3, Reason: "the launch"})
+	db.InsertPoints(context.Background(), &database.Points{From: "U2222", To: "U9876", Points: 5})
+
+	for _, user := range []string{"U1234", "U5555"} {
+		b.handleAppHomeOpenedEvent(context.Background(), &slackevents.AppHomeOpenedEvent{
+			Type: "app_home_opened",
+			User: user,
+			Tab:  "home",
+		})
+	}
+
+	for user, want := range map[string][]string{
+		"U1234": {`"text":"*Points*\n3"`, `"text":"*Rank*\n#3"`, `for the launch`, `"text":"*1.* önehundred_points"`},
+		"U5555": {`you have not received any karma yet.`, `"text":"*3.* Ů1234"`},
+	} {
+		view, ok := cs.Views[user]
+		if !ok {
+			t.Errorf("did not publish a home tab for %s", user)
+			continue
+		}
+
+		blocks, err := json.Marshal(view.Blocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(string(blocks), w) {
+				t.Errorf("published home tab %s for %s; want it to contain %s", blocks, user, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package karmabot
+
+import (
+	"fmt"
+
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/munge"
+	"github.com/slack-go/slack"
+)
+
+// maxBlocks is the maximum amount of blocks that Slack accepts in
+// a single message.
+const maxBlocks = 50
+
+// maxFields is the maximum amount of fields that Slack accepts in
+// a single section block.
+const maxFields = 10
+
+// markdown returns a text object that is formatted as mrkdwn.
+func markdown(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, text, false, false)
+}
+
+// textBlock returns a section block that consists of text.
+func textBlock(text string) slack.Block {
+	return slack.NewSectionBlock(markdown(text), nil, nil)
+}
+
+// blockOptions returns the message options that attach blocks to
+// a message. Slack rejects messages with too many blocks, so those
+// are sent as plain text instead.
+func blockOptions(blocks []slack.Block) []slack.MsgOption {
+	if len(blocks) == 0 || len(blocks) > maxBlocks {
+		return nil
+	}
+
+	return []slack.MsgOption{slack.MsgOptionBlocks(blocks...)}
+}
+
+// pointsBlocks renders a user's points after a karma operation,
+// followed by the operation's points and reason.
+func pointsBlocks(l *locale, name string, total, points int, reason string) []slack.Block {
+	operation := fmt.Sprintf("%+d", points)
+	if reason != "" {
+		operation += l.Sprintf(" for %s", reason)
+	}
+
+	return []slack.Block{
+		textBlock(fmt.Sprintf("*%s* == %d", name, total)),
+		slack.NewContextBlock("", markdown(operation)),
+	}
+}
+
+// leaderboardBlocks renders a leaderboard with one row of fields
+// per user, ranked from offset+1 on. The link to the web UI is left
+// out if url is empty.
+func leaderboardBlocks(l *locale, title, url string, leaderboard database.Leaderboard, offset int) []slack.Block {
+	blocks := []slack.Block{textBlock(title)}
+	if url != "" {
+		blocks = append(blocks, slack.NewContextBlock("", markdown(url)))
+	}
+
+	var fields []*slack.TextBlockObject
+	for i, user := range leaderboard {
+		fields = append(fields,
+			markdown(fmt.Sprintf("*%d.* %s", offset+i+1, munge.Munge(user.Name))),
+			markdown(l.Sprintf("%d points", user.Points)),
+		)
+
+		if len(fields) == maxFields || i == len(leaderboard)-1 {
+			blocks = append(blocks, slack.NewSectionBlock(nil, fields, nil))
+			fields = nil
+		}
+	}
+
+	return blocks
+}
+
+// throwbackBlocks renders a throwback, followed by its reason and
+// a timestamp that Slack shows in the reader's time zone.
+func throwbackBlocks(l *locale, throwback *database.Throwback) []slack.Block {
+	context := []slack.MixedElement{
+		markdown(l.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", throwback.Timestamp.Unix(), l.Time(throwback.Timestamp))),
+	}
+	if throwback.Reason != "" {
+		context = append([]slack.MixedElement{markdown(l.Sprintf("for %s", throwback.Reason))}, context...)
+	}
+
+	return []slack.Block{
+		textBlock(l.Sprintf("*%s* received %d points from *%s*", munge.Munge(throwback.To), throwback.Points.Points, munge.Munge(throwback.From))),
+		slack.NewContextBlock("", context...),
+	}
+}
@@ -0,0 +1,84 @@
+package karmabot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestLeaderboardBlocks(t *testing.T) {
+	var leaderboard database.Leaderboard
+	for i := 0; i < 12; i++ {
+		leaderboard = append(leaderboard, &database.User{Name: fmt.Sprintf("user%d", i), Points: 12 - i})
+	}
+
+	blocks := leaderboardBlocks(locales[defaultLocale], "*top 12 leaderboard*", "https://karma.test/leaderboard/12", leaderboard, 0)
+
+	// title, link and 24 fields in sections of 10
+	if len(blocks) != 5 {
+		t.Fatalf("got %d blocks; want %d", len(blocks), 5)
+	}
+	if blocks[1].BlockType() != slack.MBTContext {
+		t.Errorf("second block is a %s block; want a context block", blocks[1].BlockType())
+	}
+	for i, want := range []int{10, 10, 4} {
+		section := blocks[i+2].(*slack.SectionBlock)
+		if len(section.Fields) != want {
+			t.Errorf("section %d has %d fields; want %d", i, len(section.Fields), want)
+		}
+	}
+
+	if blockOptions(make([]slack.Block, maxBlocks+1)) != nil {
+		t.Errorf("blockOptions accepted more than %d blocks", maxBlocks)
+	}
+}
+
+func TestThrowbackBlocks(t *testing.T) {
+	throwback := &database.Throwback{
+		Points:    database.Points{From: "alice", To: "bob", Points: 2, Reason: "the launch"},
+		Timestamp: time.Unix(1700000000, 0),
+	}
+
+	blocks := throwbackBlocks(locales[defaultLocale], throwback)
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks; want %d", len(blocks), 2)
+	}
+
+	context := blocks[1].(*slack.ContextBlock)
+	if len(context.ContextElements.Elements) != 2 {
+		t.Fatalf("context has %d elements; want the reason and the timestamp", len(context.ContextElements.Elements))
+	}
+	if text := context.ContextElements.Elements[1].(*slack.TextBlockObject).Text; !strings.HasPrefix(text, "<!date^1700000000^") {
+		t.Errorf("timestamp is %q; want a date formatted by Slack", text)
+	}
+}
+
+func TestReplyBlocks(t *testing.T) {
+	b, cs, _ := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10, MaxPoints: 6})
+
+	for _, text := range []string{"bob++ for the launch", "karma top"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	if len(cs.SentMessages) != 2 {
+		t.Fatalf("sent messages %+v; want 2 messages", cs.SentMessages)
+	}
+
+	for i, want := range []string{`"text":"+1 for the launch"`, `"text":"*1.* önehundred_points"`} {
+		if msg := cs.SentMessages[i]; !strings.Contains(msg.Blocks, want) {
+			t.Errorf("sent message with blocks %s; want blocks containing %s", msg.Blocks, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package karmabot
+
+import (
+	"context"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+// budgetPeriod returns the period that budgets are reset every.
+func (b *Bot) budgetPeriod() string {
+	if b.Config.BudgetPeriod == "" {
+		return "day"
+	}
+
+	return b.Config.BudgetPeriod
+}
+
+// budgetStart returns the time at which the current budget period
+// started.
+func (b *Bot) budgetStart() (time.Time, error) {
+	filter, err := parseTimeRange("this "+b.budgetPeriod(), time.Now())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return filter.Since, nil
+}
+
+// remainingBudget returns the number of points that a user can
+// still give or take during the current budget period. Concurrent
+// operations are not accounted for, see Config.Budget.
+func (b *Bot) remainingBudget(ctx context.Context, user string) (int, error) {
+	since, err := b.budgetStart()
+	if err != nil {
+		return 0, err
+	}
+
+	given, err := b.Config.DB.GetPointsGiven(ctx, user, since)
+	if err != nil {
+		return 0, err
+	}
+
+	if given >= b.Config.Budget {
+		return 0, nil
+	}
+
+	return b.Config.Budget - given, nil
+}
+
+// budgetedPoints limits an operation of points from one user to
+// another to what the giver's remaining budget allows. Taking back
+// points that were given to the same recipient during the current
+// budget period refunds them, so that part of an operation is
+// allowed even when no budget remains. It returns 0 if the
+// operation is not allowed at all.
+func (b *Bot) budgetedPoints(ctx context.Context, from, to string, points int) (int, error) {
+	remaining, err := b.remainingBudget(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+
+	since, err := b.budgetStart()
+	if err != nil {
+		return 0, err
+	}
+
+	given, err := b.Config.DB.GetPointsGivenTo(ctx, from, to, since)
+	if err != nil {
+		return 0, err
+	}
+
+	// moving the net points given back towards zero is free, and
+	// only going past it costs budget
+	allowed := remaining
+	if given > 0 && points < 0 || given < 0 && points > 0 {
+		allowed += 2 * abs(given)
+	}
+
+	if points < 0 {
+		return -min(-points, allowed), nil
+	}
+
+	return min(points, allowed), nil
+}
+
+// perBudgetPeriod describes how often budgets are reset, e.g.
+// "per day".
+func (b *Bot) perBudgetPeriod(l *locale) string {
+	if b.budgetPeriod() == "week" {
+		return l.Sprintf("per week")
+	}
+
+	return l.Sprintf("per day")
+}
+
+// budgetMessage tells a user how much of their budget remains.
+func (b *Bot) budgetMessage(l *locale, remaining int) string {
+	if b.budgetPeriod() == "week" {
+		return l.Sprintf("you have %d of %d points left to give this week.", remaining, b.Config.Budget)
+	}
+
+	return l.Sprintf("you have %d of %d points left to give today.", remaining, b.Config.Budget)
+}
+
+// sendBudget tells the sender of a message how much of their
+// budget remains.
+func (b *Bot) sendBudget(ctx context.Context, ev *slackevents.MessageEvent) {
+	remaining, err := b.remainingBudget(ctx, ev.User)
+	if b.handleError(err, ev) {
+		return
+	}
+
+	b.SendReplyEphemeral(b.budgetMessage(b.locale(ev.Channel), remaining), ev)
+}
+
+func (b *Bot) printBudget(ctx context.Context, ev *slackevents.MessageEvent) {
+	if b.Config.Budget <= 0 {
+		b.SendReplyEphemeral(b.locale(ev.Channel).Sprintf("there is no limit to how many points you can give."), ev)
+		return
+	}
+
+	b.sendBudget(ctx, ev)
+}
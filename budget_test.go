@@ -0,0 +1,128 @@
+package karmabot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestBudget(t *testing.T) {
+	upvote := make(StringList, 1)
+	upvote.Set("+1")
+
+	b, cs, db := newBot(&Config{
+		MaxPoints: 6,
+		Budget:    5,
+		Reactji:   &ReactjiConfig{Enabled: true, Upvote: upvote, Downvote: make(StringList)},
+	})
+
+	for _, text := range []string{"alice+++", "bob+++++ carol++", "dave++", "karma budget"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+	b.handleReactionAddedEvent(context.Background(), &slackevents.ReactionAddedEvent{
+		Type:     "reaction_added",
+		User:     "U9876",
+		ItemUser: "onehundred_points",
+		Reaction: "+1",
+	})
+
+	want := []struct{ Channel, Text string }{
+		{"channel", "alice == 2 (+2)"},
+		{"user", "you have 3 of 5 points left to give today."},
+		{"channel", "bob == 3 (+3)"},
+		{"user", "you have 0 of 5 points left to give today."},
+		{"user", "you have 0 of 5 points left to give today."},
+		{"user", "you have 0 of 5 points left to give today."},
+		{"user", "you have 0 of 5 points left to give today."},
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent messages %+v; want %+v", cs.SentMessages, want)
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Channel != want[i].Channel || msg.Text != want[i].Text {
+			t.Errorf("sent message %+v; want %+v", *msg, want[i])
+		}
+	}
+
+	if len(db.records) != 3 {
+		t.Errorf("stored %d records; want %d", len(db.records), 3)
+	}
+}
+
+func TestBudgetReactjiRemoved(t *testing.T) {
+	upvote := make(StringList, 1)
+	upvote.Set("+1")
+
+	b, cs, _ := newBot(&Config{
+		MaxPoints: 6,
+		Budget:    5,
+		Reactji:   &ReactjiConfig{Enabled: true, Upvote: upvote, Downvote: make(StringList)},
+	})
+
+	ev := &slackevents.ReactionAddedEvent{
+		Type:     "reaction_added",
+		User:     "U9876",
+		ItemUser: "onehundred_points",
+		Reaction: "+1",
+	}
+	b.handleReactionAddedEvent(context.Background(), ev)
+	b.handleReactionRemovedEvent(context.Background(), (*slackevents.ReactionRemovedEvent)(ev))
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma budget",
+		Channel: "channel",
+		User:    "U9876",
+	})
+
+	want := "you have 5 of 5 points left to give today."
+	if len(cs.SentMessages) != 3 || cs.SentMessages[2].Text != want {
+		t.Errorf("sent messages %+v; want %q last", cs.SentMessages, want)
+	}
+}
+
+func TestBudgetTakeBack(t *testing.T) {
+	b, cs, db := newBot(&Config{
+		MaxPoints: 10,
+		Budget:    5,
+	})
+
+	// taking back points refunds them even when no budget remains,
+	// but going past zero costs budget again
+	for _, text := range []string{"alice++++++", "alice--", "bob--", "alice----------"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	want := []struct{ Channel, Text string }{
+		{"channel", "alice == 5 (+5)"},
+		{"user", "you have 0 of 5 points left to give today."},
+		{"channel", "alice == 4 (-1)"},
+		{"user", "you have 1 of 5 points left to give today."},
+		{"channel", "bob == -1 (-1)"},
+		{"user", "you have 0 of 5 points left to give today."},
+		{"channel", "alice == -4 (-8)"},
+		{"user", "you have 0 of 5 points left to give today."},
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent messages %+v; want %+v", cs.SentMessages, want)
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Channel != want[i].Channel || msg.Text != want[i].Text {
+			t.Errorf("sent message %+v; want %+v", *msg, want[i])
+		}
+	}
+
+	if len(db.records) != 5 {
+		t.Errorf("stored %d records; want %d", len(db.records), 5)
+	}
+}
@@ -1,59 +1,123 @@
 package karmabot
 
 import (
-	"github.com/nlopes/slack"
+	"encoding/json"
+	"errors"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
 )
 
 type TestChatService struct {
-	IncomingEvents chan slack.RTMEvent
+	IncomingEvents chan socketmode.Event
+
+	// Acked are the envelope IDs of the acknowledged requests.
+	Acked []string
 
-	SentMessages []*slack.OutgoingMessage
-	id           int
+	SentMessages    []*TestMessage
+	UpdatedMessages []*TestMessage
+	Views           map[string]slack.HomeTabViewRequest
+
+	// MissingUsers are user IDs that GetUserInfo fails to look up.
+	MissingUsers StringList
+}
+
+// TestMessage is a message that has been sent through the TestChatService.
+// Blocks is the JSON encoding of the message's blocks, if any.
+type TestMessage struct {
+	Channel, Text, Blocks string
 }
 
 func newTestChatService() ChatService {
 	return &TestChatService{}
 }
 
-func (t *TestChatService) IncomingEventsChan() chan slack.RTMEvent {
+func (t *TestChatService) IncomingEventsChan() chan socketmode.Event {
 	return t.IncomingEvents
 }
 
-func (t *TestChatService) OpenIMChannel(user string) (bool, bool, string, error) {
-	return true, true, user, nil
+func (t *TestChatService) GetSocketClient() *socketmode.Client {
+	return nil
+}
+
+func (t *TestChatService) Ack(req socketmode.Request) {
+	t.Acked = append(t.Acked, req.EnvelopeID)
 }
 
 func (t *TestChatService) GetUserInfo(user string) (*slack.User, error) {
+	if t.MissingUsers.Contains(user) {
+		return nil, errors.New("user_not_found")
+	}
+
 	return &slack.User{
 		ID:   user,
 		Name: user,
 	}, nil
 }
 
-func (t *TestChatService) NewOutgoingMessage(text string, channel string, options ...slack.RTMsgOption) *slack.OutgoingMessage {
-	t.id++
-	return &slack.OutgoingMessage{
-		ID:      t.id,
-		Type:    "message",
+func (t *TestChatService) GetConversationInfo(channel string) (*slack.Channel, error) {
+	return &slack.Channel{
+		GroupConversation: slack.GroupConversation{
+			Conversation: slack.Conversation{ID: channel},
+			Name:         channel,
+		},
+	}, nil
+}
+
+func (t *TestChatService) Respond(responseURL string, msg *slack.WebhookMessage) error {
+	var blocks []byte
+	if msg.Blocks != nil {
+		blocks, _ = json.Marshal(msg.Blocks)
+	}
+
+	t.SentMessages = append(t.SentMessages, &TestMessage{
+		Channel: responseURL,
+		Text:    msg.Text,
+		Blocks:  string(blocks),
+	})
+
+	return nil
+}
+
+func (t *TestChatService) PublishView(userID string, view slack.HomeTabViewRequest) error {
+	if t.Views == nil {
+		t.Views = make(map[string]slack.HomeTabViewRequest)
+	}
+	t.Views[userID] = view
+
+	return nil
+}
+
+func (t *TestChatService) UpdateMessage(channel, timestamp, text string, options ...slack.MsgOption) error {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", "", "", options...)
+
+	t.UpdatedMessages = append(t.UpdatedMessages, &TestMessage{
 		Channel: channel,
 		Text:    text,
-	}
+		Blocks:  values.Get("blocks"),
+	})
+
+	return nil
 }
 
-func (t *TestChatService) SendMessage(m *slack.OutgoingMessage) {
-	t.SentMessages = append(t.SentMessages, m)
+func (t *TestChatService) SendMessage(channel, text string, options ...slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", "", "", options...)
+
+	t.SentMessages = append(t.SentMessages, &TestMessage{
+		Channel: channel,
+		Text:    text,
+		Blocks:  values.Get("blocks"),
+	})
+
+	return channel, "", nil
 }
 
 func (t *TestChatService) PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error) {
 	// run options
-	_, values, _ := slack.UnsafeApplyMsgOptions("", "", options...)
+	_, values, _ := slack.UnsafeApplyMsgOptions("", "", "", options...)
 	message := values.Get("text")
 
-	t.SendMessage(
-		t.NewOutgoingMessage(
-			message, "user",
-		),
-	)
+	t.SendMessage("user", message, options...)
 
 	return "", nil
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aybabtme/log"
+	"github.com/kamaln7/karmabot"
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/database/memdb"
+)
+
+// A store is a database that can be scoped to the karma
+// of a single Slack team.
+type store struct {
+	// forTeam returns the database scoped to a team.
+	forTeam func(team string) karmabot.Database
+
+	// close is called on shutdown.
+	close func() error
+}
+
+// openStore opens the database that the DSN points to. memory://
+// DSNs select an in-memory database that is saved to the path
+// following memory://, if any, on shutdown.
+func openStore(dsn string, timeout time.Duration, ll *log.Log) (*store, error) {
+	if strings.HasPrefix(dsn, memdb.Scheme) {
+		db, err := memdb.New(&memdb.Config{
+			Path: strings.TrimPrefix(dsn, memdb.Scheme),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &store{
+			forTeam: func(team string) karmabot.Database { return db.WithTeam(team) },
+			close:   db.Save,
+		}, nil
+	}
+
+	db, err := database.New(&database.Config{
+		DSN:     dsn,
+		Log:     ll,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &store{
+		forTeam: func(team string) karmabot.Database { return db.WithTeam(team) },
+		close:   db.SQL.Close,
+	}, nil
+}
@@ -1,29 +1,32 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/aybabtme/log"
+	"github.com/kamaln7/envy"
 	"github.com/kamaln7/karmabot"
-	"github.com/kamaln7/karmabot/database"
 	karmabotui "github.com/kamaln7/karmabot/ui"
 	"github.com/kamaln7/karmabot/ui/blankui"
 	"github.com/kamaln7/karmabot/ui/webui"
-	"github.com/aybabtme/log"
-	"github.com/kamaln7/envy"
 
 	_ "github.com/joho/godotenv"
-    "github.com/slack-go/slack"
-    "github.com/slack-go/slack/socketmode"
-	"os"
-	"fmt"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
 )
 
 // cli flags
 var (
 	bottoken         = flag.String("bottoken", "", "Bot token")
 	apptoken         = flag.String("apptoken", "", "App token")
-	dbpath           = flag.String("db", "./db.sqlite3", "path to sqlite database")
+	dbdsn            = flag.String("db", "./db.sqlite3", "path to sqlite database, a postgres:// DSN or memory://[snapshot path]")
+	dbtimeout        = flag.Duration("db.timeout", 5*time.Second, "the maximum duration of a database query (0 disables the timeout)")
 	maxpoints        = flag.Int("maxpoints", 6, "the maximum amount of points that users can give/take at once")
 	leaderboardlimit = flag.Int("leaderboardlimit", 10, "the default amount of users to list in the leaderboard")
 	debug            = flag.Bool("debug", false, "set debug mode")
@@ -37,9 +40,21 @@ var (
 	upvotereactji    = make(karmabot.StringList, 0)
 	downvotereactji  = make(karmabot.StringList, 0)
 	aliases          = make(karmabot.StringList, 0)
+	channellocales   = make(karmabot.StringList, 0)
 	selfkarma        = flag.Bool("selfkarma", true, "allow users to add/remove karma to themselves")
+	publiccommands   = flag.Bool("publiccommands", false, "make replies to slash commands visible to everyone in the channel")
+	budget           = flag.Int("budget", 0, "the amount of points that every user can give/take per budget period (0 disables the budget)")
+	budgetperiod     = flag.String("budget.period", "day", "how often budgets are reset (day, week)")
+	decay            = flag.Int("decay", 0, "the half-life of karma points in days, after which they are worth half as much on the leaderboard (0 disables decay)")
+	undowindow       = flag.Duration("undowindow", 5*time.Minute, "how long users can undo their latest karma operation for (0 disables undo)")
 	replytype        = flag.String("replytype", "message", "how to reply to commands (message, thread)")
-	socketdebug	     = flag.Bool("socketdebug", true, "set socketmode debug mode")
+	triggers         = flag.String("triggers", "karma,karmabot", "a comma-separated list of the words that commands start with")
+	mention          = flag.Bool("mention", false, "also run commands that start with a mention of the bot")
+	locale           = flag.String("locale", "en", "the language to reply in (en, de)")
+	socketdebug      = flag.Bool("socketdebug", true, "set socketmode debug mode")
+	workspacesfile   = flag.String("workspaces", "", "path to a JSON file listing the slack workspaces to connect to")
+	templatesfile    = flag.String("templates", "", "path to a JSON file of reply templates")
+	webuiteam        = flag.String("webui.team", "", "the slack team ID whose karma the web ui shows")
 )
 
 func main() {
@@ -51,6 +66,7 @@ func main() {
 
 	flag.Var(&blacklist, "blacklist", "blacklist users from having karma operations applied on them")
 	flag.Var(&aliases, "alias", "alias different users to one user")
+	flag.Var(&channellocales, "locale.channel", "reply in another language in a channel, e.g. C0123456789=de")
 	flag.Var(&upvotereactji, "reactji.upvote", "a list of reactjis to use for upvotes")
 	flag.Var(&downvotereactji, "reactji.downvote", "a list of reactjis to use for downvotes")
 
@@ -61,6 +77,10 @@ func main() {
 
 	ll.Info("starting karmabot")
 
+	if *budgetperiod != "day" && *budgetperiod != "week" {
+		ll.KV("period", *budgetperiod).Fatal("the budget period must be either day or week")
+	}
+
 	// reactjis
 
 	// reactji defaults
@@ -79,52 +99,133 @@ func main() {
 		Downvote: downvotereactji,
 	}
 
-	// format aliases
-	aliasMap := make(karmabot.UserAliases, 0)
-	for k := range aliases {
-		users := strings.Split(k, "++")
-		if len(users) <= 1 {
-			ll.Fatal("invalid alias format. see documentation")
+	// locales
+
+	if !karmabot.IsLocale(*locale) {
+		ll.KV("locale", *locale).KV("locales", strings.Join(karmabot.Locales(), ", ")).Fatal("unknown locale")
+	}
+
+	channelLocales := make(map[string]string)
+	for k := range channellocales {
+		parts := strings.SplitN(k, "=", 2)
+		if len(parts) != 2 || !karmabot.IsLocale(parts[1]) {
+			ll.KV("locale", k).KV("locales", strings.Join(karmabot.Locales(), ", ")).Fatal("channel locales must look like <channel ID>=<locale>")
+		}
+
+		channelLocales[parts[0]] = parts[1]
+	}
+
+	// reply templates
+
+	var templates *karmabot.Templates
+	if *templatesfile != "" {
+		var err error
+		templates, err = loadTemplates(*templatesfile)
+		if err != nil {
+			ll.KV("path", *templatesfile).Err(err).Fatal("could not load templates")
 		}
+	}
 
-		user := users[0]
-		for _, alias := range users[1:] {
-			aliasMap[alias] = user
+	// format triggers
+	var triggerWords []string
+	for _, trigger := range strings.Split(*triggers, ",") {
+		if trigger = strings.TrimSpace(trigger); trigger != "" {
+			triggerWords = append(triggerWords, trigger)
 		}
 	}
 
+	// format aliases
+	var globalAliases []string
+	for k := range aliases {
+		globalAliases = append(globalAliases, k)
+	}
+
 	// database
 
-	db, err := database.New(&database.Config{
-		Path: *dbpath,
-	})
+	db, err := openStore(*dbdsn, *dbtimeout, ll.KV("service", "database"))
 
 	if err != nil {
-		ll.KV("path", *dbpath).Err(err).Fatal("could not open sqlite db")
+		ll.KV("db", *dbdsn).Err(err).Fatal("could not open database")
 	}
 
-	// Adding new code here
-    //godotenv.Load(".env")
-   
-    bottoken := os.Getenv("BOT_TOKEN")
-    apptoken := os.Getenv("APP_TOKEN")
-	fmt.Println("bottoken: ", bottoken)
-	fmt.Println("apptoken: ", apptoken)
-	
-	if bottoken == "" {
-		ll.Fatal("please pass the slack Bot token (see `karmabot -h` for help or set environment variables BOT_TOKEN")
+	// workspaces
+
+	var workspaces []*workspace
+	if *workspacesfile != "" {
+		workspaces, err = loadWorkspaces(*workspacesfile)
+		if err != nil {
+			ll.KV("path", *workspacesfile).Err(err).Fatal("could not load workspaces")
+		}
+	} else {
+		if *bottoken == "" {
+			*bottoken = os.Getenv("BOT_TOKEN")
+		}
+		if *apptoken == "" {
+			*apptoken = os.Getenv("APP_TOKEN")
+		}
+
+		if *bottoken == "" {
+			ll.Fatal("please pass the slack Bot token (see `karmabot -h` for help or set environment variables BOT_TOKEN")
+		}
+
+		if *apptoken == "" {
+			ll.Fatal("please pass the slack App token (see `karmabot -h` for help or set environment variables APP_TOKEN")
+		}
+
+		workspaces = []*workspace{
+			{
+				BotToken: *bottoken,
+				AppToken: *apptoken,
+			},
+		}
 	}
 
-	if apptoken == "" {
-		ll.Fatal("please pass the slack App token (see `karmabot -h` for help or set environment variables APP_TOKEN")
+	type connection struct {
+		team         string
+		botUserID    string
+		client       *slack.Client
+		socketClient *socketmode.Client
+		workspace    *workspace
+	}
+	connections := make([]*connection, len(workspaces))
+	for i, ws := range workspaces {
+		client := slack.New(ws.BotToken, slack.OptionDebug(*socketdebug), slack.OptionAppLevelToken(ws.AppToken))
+
+		// a single workspace keeps using the records that
+		// do not belong to any team
+		var team, botUserID string
+		if *workspacesfile != "" || *mention {
+			auth, err := client.AuthTest()
+			if err != nil {
+				ll.Err(err).Fatal("could not authenticate with slack")
+			}
+
+			if *workspacesfile != "" {
+				team = auth.TeamID
+			}
+			if *mention {
+				botUserID = auth.UserID
+			}
+		}
+
+		connections[i] = &connection{
+			team:      team,
+			botUserID: botUserID,
+			client:    client,
+			socketClient: socketmode.New(
+				client,
+				socketmode.OptionDebug(*socketdebug),
+			),
+			workspace: ws,
+		}
+	}
+
+	// the web ui only shows the karma of a single team, so only
+	// that team's bot links to it
+	uiTeam := *webuiteam
+	if uiTeam == "" {
+		uiTeam = connections[0].team
 	}
- 
-    client := slack.New(bottoken, slack.OptionDebug(*socketdebug), slack.OptionAppLevelToken(apptoken))
- 
-    socketClient := socketmode.New(
-        client,
-        socketmode.OptionDebug(*socketdebug),
-	)
 
 	var ui karmabotui.Provider
 	if *webuipath != "" && *webuilistenaddr != "" {
@@ -136,7 +237,7 @@ func main() {
 			LeaderboardLimit: *leaderboardlimit,
 			Log:              ll.KV("provider", "webui"),
 			Debug:            *debug,
-			DB:               db,
+			DB:               db.forTeam(uiTeam),
 		})
 
 		if err != nil {
@@ -147,26 +248,84 @@ func main() {
 	}
 	go ui.Listen()
 
-	bot := karmabot.NewBot(&karmabot.Config{
-		Slack: &karmabot.SlackChatService{
-			Client: *socketClient,
-			API: client,
-		},
-		UI:               ui,
-		Debug:            *debug,
-		MaxPoints:        *maxpoints,
-		LeaderboardLimit: *leaderboardlimit,
-		Log:              ll,
-		DB:               db,
-		UserBlacklist:    blacklist,
-		Reactji:          reactjiConfig,
-		Motivate:         *motivate,
-		Aliases:          aliasMap,
-		SelfKarma:        *selfkarma,
-		ReplyType:        *replytype,
-	})
-
-	go bot.Listen()
-
-	socketClient.Run()
+	// shut down gracefully on SIGINT and SIGTERM
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	errs := make(chan error, len(connections))
+	for _, conn := range connections {
+		aliasMap := make(karmabot.UserAliases, 0)
+		err := parseAliases(append(globalAliases, conn.workspace.Aliases...), aliasMap)
+		if err != nil {
+			ll.Err(err).Fatal("could not parse aliases")
+		}
+
+		userBlacklist := make(karmabot.StringList, 0)
+		for k := range blacklist {
+			userBlacklist.Set(k)
+		}
+		for _, user := range conn.workspace.Blacklist {
+			userBlacklist.Set(user)
+		}
+
+		botUI := ui
+		if conn.team != uiTeam {
+			botUI = blankui.New()
+		}
+
+		bot := karmabot.NewBot(&karmabot.Config{
+			Slack: &karmabot.SlackChatService{
+				Client: *conn.socketClient,
+				API:    conn.client,
+			},
+			UI:               botUI,
+			Debug:            *debug,
+			MaxPoints:        *maxpoints,
+			LeaderboardLimit: *leaderboardlimit,
+			Log:              ll.KV("team", conn.team),
+			DB:               db.forTeam(conn.team),
+			Team:             conn.team,
+			UserBlacklist:    userBlacklist,
+			Reactji:          reactjiConfig,
+			Motivate:         *motivate,
+			Aliases:          aliasMap,
+			SelfKarma:        *selfkarma,
+			PublicCommands:   *publiccommands,
+			UndoWindow:       *undowindow,
+			Budget:           *budget,
+			BudgetPeriod:     *budgetperiod,
+			HalfLife:         time.Duration(*decay) * 24 * time.Hour,
+			ReplyType:        *replytype,
+			Triggers:         triggerWords,
+			BotUserID:        conn.botUserID,
+			Locale:           *locale,
+			ChannelLocales:   channelLocales,
+			Templates:        templates,
+		})
+
+		go bot.Listen(ctx)
+
+		go func(socketClient *socketmode.Client) {
+			errs <- socketClient.RunContext(ctx)
+		}(conn.socketClient)
+	}
+
+	select {
+	case sig := <-signals:
+		ll.KV("signal", sig).Info("shutting down")
+		err = nil
+	case err = <-errs:
+	}
+	cancel()
+
+	if closeErr := db.close(); closeErr != nil {
+		ll.Err(closeErr).Error("could not close database")
+	}
+
+	if err != nil {
+		ll.Err(err).Fatal("socket mode connection closed")
+	}
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/kamaln7/karmabot"
+)
+
+// loadTemplates reads a JSON object of reply templates, keyed by
+// their name, from a file.
+func loadTemplates(path string) (*karmabot.Templates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sources map[string]string
+	err = json.NewDecoder(f).Decode(&sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return karmabot.ParseTemplates(sources)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/kamaln7/karmabot"
+)
+
+// A workspace is a Slack workspace that karmabot connects to.
+// Blacklisted users and aliases are added to the ones passed
+// through the -blacklist and -alias options.
+type workspace struct {
+	BotToken  string   `json:"bottoken"`
+	AppToken  string   `json:"apptoken"`
+	Blacklist []string `json:"blacklist"`
+	Aliases   []string `json:"aliases"`
+}
+
+// loadWorkspaces reads a JSON array of workspaces from a file.
+func loadWorkspaces(path string) ([]*workspace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var workspaces []*workspace
+	err = json.NewDecoder(f).Decode(&workspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(workspaces) == 0 {
+		return nil, errors.New("no workspaces configured")
+	}
+
+	for _, ws := range workspaces {
+		if ws.BotToken == "" || ws.AppToken == "" {
+			return nil, errors.New("every workspace needs a bottoken and an apptoken")
+		}
+	}
+
+	return workspaces, nil
+}
+
+// parseAliases formats a list of main++alias1++...++aliasN
+// aliases as a map of alias -> main username.
+func parseAliases(aliases []string, aliasMap karmabot.UserAliases) error {
+	for _, k := range aliases {
+		users := strings.Split(k, "++")
+		if len(users) <= 1 {
+			return errors.New("invalid alias format. see documentation")
+		}
+
+		user := users[0]
+		for _, alias := range users[1:] {
+			aliasMap[alias] = user
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kamaln7/karmabot"
+)
+
+func TestLoadWorkspaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "karmabot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name, file string
+		want       []*workspace
+		wantErr    bool
+	}{
+		{
+			name: "workspaces",
+			file: `[
+				{"bottoken": "xoxb-a", "apptoken": "xapp-a", "blacklist": ["bob"], "aliases": ["alice++ally"]},
+				{"bottoken": "xoxb-b", "apptoken": "xapp-b"}
+			]`,
+			want: []*workspace{
+				{BotToken: "xoxb-a", AppToken: "xapp-a", Blacklist: []string{"bob"}, Aliases: []string{"alice++ally"}},
+				{BotToken: "xoxb-b", AppToken: "xapp-b"},
+			},
+		},
+		{
+			name:    "no workspaces",
+			file:    `[]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing app token",
+			file:    `[{"bottoken": "xoxb-a"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing bot token",
+			file:    `[{"apptoken": "xapp-a"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			file:    `{"bottoken": "xoxb-a"`,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.json", i))
+		err := ioutil.WriteFile(path, []byte(tt.file), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := loadWorkspaces(path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%d. %s: loadWorkspaces returned %+v; want an error", i, tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. %s: loadWorkspaces: %v", i, tt.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d. %s: loadWorkspaces = %+v; want %+v", i, tt.name, got, tt.want)
+		}
+	}
+
+	_, err = loadWorkspaces(filepath.Join(dir, "missing.json"))
+	if err == nil {
+		t.Errorf("loadWorkspaces succeeded for a missing file; want an error")
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases []string
+		want    karmabot.UserAliases
+		wantErr bool
+	}{
+		{
+			name:    "single alias",
+			aliases: []string{"alice++ally"},
+			want:    karmabot.UserAliases{"ally": "alice"},
+		},
+		{
+			name:    "multiple aliases",
+			aliases: []string{"alice++ally++al", "bob++bobby"},
+			want:    karmabot.UserAliases{"ally": "alice", "al": "alice", "bobby": "bob"},
+		},
+		{
+			name:    "no aliases",
+			aliases: nil,
+			want:    karmabot.UserAliases{},
+		},
+		{
+			name:    "missing alias",
+			aliases: []string{"alice"},
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		got := make(karmabot.UserAliases)
+		err := parseAliases(tt.aliases, got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%d. %s: parseAliases(%q) succeeded; want an error", i, tt.name, tt.aliases)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. %s: parseAliases(%q): %v", i, tt.name, tt.aliases, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d. %s: parseAliases(%q) = %v; want %v", i, tt.name, tt.aliases, got, tt.want)
+		}
+	}
+}
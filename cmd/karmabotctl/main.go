@@ -33,10 +33,15 @@ func main() {
 
 	// general flags
 
-	dbpath := cli.StringFlag{
+	dbdsn := cli.StringFlag{
 		Name:  "db",
 		Value: "./db.sqlite3",
-		Usage: "path to sqlite database",
+		Usage: "path to sqlite database or a postgres:// DSN",
+	}
+
+	team := cli.StringFlag{
+		Name:  "team",
+		Usage: "slack team ID, for databases that are shared by multiple workspaces",
 	}
 
 	debug := cli.BoolFlag{
@@ -68,7 +73,8 @@ func main() {
 			Name:  "serve",
 			Usage: "start a webserver",
 			Flags: []cli.Flag{
-				dbpath,
+				dbdsn,
+				team,
 				debug,
 				leaderboardlimit,
 				cli.StringFlag{
@@ -99,7 +105,8 @@ func main() {
 			Name:  "add",
 			Usage: "add karma to a user",
 			Flags: []cli.Flag{
-				dbpath,
+				dbdsn,
+				team,
 				cli.StringFlag{
 					Name: "from",
 				},
@@ -119,7 +126,8 @@ func main() {
 			Name:  "migrate",
 			Usage: "move a user's karma to another user",
 			Flags: []cli.Flag{
-				dbpath,
+				dbdsn,
+				team,
 				cli.StringFlag{
 					Name: "from",
 				},
@@ -136,7 +144,8 @@ func main() {
 			Name:  "reset",
 			Usage: "reset a user's karma",
 			Flags: []cli.Flag{
-				dbpath,
+				dbdsn,
+				team,
 				cli.StringFlag{
 					Name: "user",
 				},
@@ -147,7 +156,8 @@ func main() {
 			Name:  "set",
 			Usage: "set a user's karma to a specific number",
 			Flags: []cli.Flag{
-				dbpath,
+				dbdsn,
+				team,
 				cli.StringFlag{
 					Name: "user",
 				},
@@ -157,11 +167,45 @@ func main() {
 			},
 			Action: cc.SetKarma,
 		},
+		{
+			Name:  "history",
+			Usage: "list the latest karma operations on a user",
+			Flags: []cli.Flag{
+				dbdsn,
+				team,
+				cli.StringFlag{
+					Name: "user",
+				},
+				cli.IntFlag{
+					Name:  "limit",
+					Value: 10,
+				},
+				cli.IntFlag{
+					Name: "offset",
+				},
+			},
+			Action: cc.GetHistory,
+		},
+		{
+			Name:  "backfill-ids",
+			Usage: "replace usernames in existing karma records with slack user IDs",
+			Flags: []cli.Flag{
+				dbdsn,
+				team,
+				cli.StringFlag{
+					Name:   "bottoken",
+					Usage:  "slack Bot token",
+					EnvVar: "BOT_TOKEN",
+				},
+			},
+			Action: cc.BackfillUserIDs,
+		},
 		{
 			Name:  "throwback",
 			Usage: "get a karma throwback for a user",
 			Flags: []cli.Flag{
-				dbpath,
+				dbdsn,
+				team,
 				cli.StringFlag{
 					Name: "user",
 				},
@@ -170,6 +214,41 @@ func main() {
 		},
 	}
 
+	// db
+
+	dbCommands := []cli.Command{
+		{
+			Name:   "migrate",
+			Usage:  "apply pending database migrations",
+			Flags:  []cli.Flag{dbdsn},
+			Action: cc.Migrate,
+		},
+		{
+			Name:  "assign-team",
+			Usage: "move records that do not belong to any team to a slack team",
+			Flags: []cli.Flag{
+				dbdsn,
+				cli.StringFlag{
+					Name:  "team",
+					Usage: "slack team ID",
+				},
+			},
+			Action: cc.AssignTeam,
+		},
+		{
+			Name:   "rebuild-totals",
+			Usage:  "recompute every user's points totals from the karma records",
+			Flags:  []cli.Flag{dbdsn},
+			Action: cc.RebuildTotals,
+		},
+		{
+			Name:   "status",
+			Usage:  "list database migrations and whether they have been applied",
+			Flags:  []cli.Flag{dbdsn},
+			Action: cc.MigrationStatus,
+		},
+	}
+
 	// main app
 
 	app.Commands = []cli.Command{
@@ -181,6 +260,10 @@ func main() {
 			Name:        "webui",
 			Subcommands: webuiCommands,
 		},
+		{
+			Name:        "db",
+			Subcommands: dbCommands,
+		},
 	}
 
 	app.Run(os.Args)
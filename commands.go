@@ -0,0 +1,346 @@
+package karmabot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+// A chatCommand is a command that karmabot responds to in chat.
+type chatCommand struct {
+	// names are what `karma help` looks the command up by. The
+	// first name is the command's main name.
+	names []string
+
+	// syntax lists the ways to run the command. Syntaxes are shown
+	// as they are rather than translated.
+	syntax []string
+
+	// usage describes how to run commands that have no syntax, e.g.
+	// reacting to a message.
+	usage string
+
+	// summary describes the command in a few words. Usages and
+	// summaries are translated when they are shown.
+	summary string
+
+	// regex matches the messages that run the command. Commands
+	// without a regex are only help topics.
+	regex *regexp.Regexp
+
+	// run runs the command.
+	run func(b *Bot, ctx context.Context, ev *slackevents.MessageEvent)
+
+	// enabled reports whether the bot's configuration enables the
+	// command. Commands with a nil enabled are always enabled.
+	enabled func(b *Bot) bool
+
+	// help explains the command in detail, taking the bot's
+	// configuration into account.
+	help func(b *Bot, l *locale) string
+}
+
+// usageText describes how to run the command.
+func (c *chatCommand) usageText(l *locale) string {
+	if len(c.syntax) == 0 {
+		return l.Sprintf(c.usage)
+	}
+
+	text := c.syntax[0]
+	for _, syntax := range c.syntax[1:] {
+		text = l.Sprintf("%s or %s", text, syntax)
+	}
+
+	return text
+}
+
+// isEnabled reports whether the command is enabled for a bot.
+func (c *chatCommand) isEnabled(b *Bot) bool {
+	return c.enabled == nil || c.enabled(b)
+}
+
+// newChatCommands lists every chat command in the order in which
+// messages are matched against them. Commands start with trigger in
+// help texts and are matched by the regular expressions in r.
+func newChatCommands(trigger string, r *botRegexps) []*chatCommand {
+	return []*chatCommand{
+		{
+			names:   []string{"motivate", "?m", "!m"},
+			syntax:  []string{"`?m <user>`", "`!m <user>`"},
+			summary: "give a user a point for doing good work",
+			regex:   r.Motivate,
+			run: func(b *Bot, ctx context.Context, ev *slackevents.MessageEvent) {
+				// convert motivates into karmabot syntax
+				match := b.regexps.Motivate.FindStringSubmatch(ev.Text)
+				ev.Text = match[1] + "++ for " + motivateReason
+				b.givePoints(ctx, ev)
+			},
+			enabled: func(b *Bot) bool { return b.Config.Motivate },
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("this is the same as `<user>++ for doing good work`. see <http://motivate.im/|motivate.im>.")
+			},
+		},
+		{
+			names:   []string{"url", "web", "link"},
+			syntax:  []string{fmt.Sprintf("`%s url`", trigger)},
+			summary: "link to the web UI",
+			regex:   r.URL,
+			run: func(b *Bot, ctx context.Context, ev *slackevents.MessageEvent) {
+				b.printURL(ev)
+			},
+			enabled: func(b *Bot) bool { return b.webURL() != "" },
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("the web UI lists the leaderboard for any period of time. it is available at %s.", b.webURL())
+			},
+		},
+		{
+			names:   []string{"give", "++", "--"},
+			syntax:  []string{"`<user>++ [for <reason>]`", "`<user>-- [for <reason>]`"},
+			summary: "give or take karma",
+			regex:   r.GiveKarma,
+			run:     (*Bot).givePoints,
+			help: func(b *Bot, l *locale) string {
+				text := l.Sprintf("every extra `+` or `-` gives or takes another point, up to %d points at once. e.g. `alice+++ for the launch` gives alice 2 points.\n", b.Config.MaxPoints)
+				text += l.Sprintf("you can give karma to several users at once: `alice++ bob++ for the launch`.\n")
+				text += l.Sprintf("karma can be given to anything, not only people, e.g. `coffee++`.")
+				if !b.Config.SelfKarma {
+					text += l.Sprintf("\nyou cannot give karma to yourself.")
+				}
+				if b.Config.Budget > 0 {
+					text += l.Sprintf("\nyou can give or take up to %d points %s. send `%s budget` to see how many you have left.", b.Config.Budget, b.perBudgetPeriod(l), trigger)
+				}
+				return text
+			},
+		},
+		{
+			names:   []string{"top", "leaderboard", "highscores"},
+			syntax:  []string{fmt.Sprintf("`%s top [n] [people|things] [here] [period] [raw]`", trigger)},
+			summary: "show the leaderboard",
+			regex:   r.Leaderboard,
+			run:     (*Bot).printLeaderboard,
+			help: func(b *Bot, l *locale) string {
+				text := l.Sprintf("lists the top %d users unless you pass another number.\n", b.Config.LeaderboardLimit)
+				text += l.Sprintf("`people` and `things` only list Slack users or everything else, and `here` only counts karma given in the current channel.\n")
+				text += l.Sprintf("the period can be `today`, `this <day|week|month|year>`, `last <day|week|month|year>` or `since <YYYY-MM-DD>`, e.g. `%s top 5 this week`.", trigger)
+				if b.Config.HalfLife > 0 {
+					text += l.Sprintf("\npoints lose half of their value every %s. append `raw` to rank users by their all-time totals instead.", formatDuration(l, b.Config.HalfLife))
+				}
+				return text
+			},
+		},
+		{
+			names:   []string{"givers"},
+			syntax:  []string{fmt.Sprintf("`%s givers [n]`", trigger)},
+			summary: "show who has given the most karma",
+			regex:   r.Givers,
+			run:     (*Bot).printGivers,
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("lists the top %d users who have given the most points to others, unless you pass another number.", b.Config.LeaderboardLimit)
+			},
+		},
+		{
+			names:   []string{"throwback"},
+			syntax:  []string{fmt.Sprintf("`%s throwback [user]`", trigger)},
+			summary: "show a random karma operation",
+			regex:   r.Throwback,
+			run:     (*Bot).getThrowback,
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("shows a random karma operation on a user, or on you if you do not pass a user.")
+			},
+		},
+		{
+			names:   []string{"history"},
+			syntax:  []string{fmt.Sprintf("`%s history [user] [n]`", trigger)},
+			summary: "list the latest karma operations",
+			regex:   r.History,
+			run:     (*Bot).printHistory,
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("lists the latest %d karma operations on a user, or on you if you do not pass a user, unless you pass another number.", defaultHistoryLimit)
+			},
+		},
+		{
+			names:   []string{"reasons"},
+			syntax:  []string{fmt.Sprintf("`%s reasons [user] [n]`", trigger)},
+			summary: "show why a user has received karma",
+			regex:   r.Reasons,
+			run:     (*Bot).printReasons,
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("lists the %d reasons for which a user, or you if you do not pass a user, has received karma most often, unless you pass another number. reasons that only differ in case are counted together.", defaultReasonsLimit)
+			},
+		},
+		{
+			names:   []string{"undo"},
+			syntax:  []string{fmt.Sprintf("`%s undo`", trigger)},
+			summary: "undo your latest karma operation",
+			regex:   r.Undo,
+			run:     (*Bot).undoPoints,
+			enabled: func(b *Bot) bool { return b.Config.UndoWindow > 0 },
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("undoes the latest karma operation that you performed within the last %s.", formatDuration(l, b.Config.UndoWindow))
+			},
+		},
+		{
+			names:   []string{"budget"},
+			syntax:  []string{fmt.Sprintf("`%s budget`", trigger)},
+			summary: "show how many points you have left to give",
+			regex:   r.Budget,
+			run:     (*Bot).printBudget,
+			enabled: func(b *Bot) bool { return b.Config.Budget > 0 },
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("everyone can give or take up to %d points %s. operations that exceed your budget are reduced to the points that you have left.", b.Config.Budget, b.perBudgetPeriod(l))
+			},
+		},
+		{
+			names:   []string{"reactji", "reactjis", "reactions"},
+			usage:   "react to a message",
+			summary: "upvote or downvote the author of a message",
+			enabled: func(b *Bot) bool { return b.Config.Reactji != nil && b.Config.Reactji.Enabled },
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("react to a message with %s to give its author a point, or with %s to take one. removing the reactji undoes the vote.", reactjiList(b.Config.Reactji.Upvote), reactjiList(b.Config.Reactji.Downvote))
+			},
+		},
+		{
+			names:   []string{"help"},
+			syntax:  []string{fmt.Sprintf("`%s help [command]`", trigger)},
+			summary: "show this help, or more about a command",
+			regex:   r.Help,
+			run:     (*Bot).printHelp,
+			help: func(b *Bot, l *locale) string {
+				return l.Sprintf("lists every command, or explains a single command, e.g. `%s help top`.", trigger)
+			},
+		},
+		{
+			names:   []string{"query", "=="},
+			syntax:  []string{"`<user>==`"},
+			summary: "show a user's points",
+			regex:   r.QueryKarma,
+			run:     (*Bot).queryKarma,
+			help: func(b *Bot, l *locale) string {
+				text := l.Sprintf("shows how many points a user has.")
+				if b.Config.HalfLife > 0 {
+					text += l.Sprintf("\npoints lose half of their value every %s. send `<user>== raw` to see their all-time total instead.", formatDuration(l, b.Config.HalfLife))
+				}
+				return text
+			},
+		},
+	}
+}
+
+// runCommand runs the first enabled command that matches a message.
+func (b *Bot) runCommand(ctx context.Context, ev *slackevents.MessageEvent) {
+	for _, cmd := range b.chatCommands {
+		if cmd.regex == nil || !cmd.regex.MatchString(ev.Text) || !cmd.isEnabled(b) {
+			continue
+		}
+
+		cmd.run(b, ctx, ev)
+		return
+	}
+}
+
+// findCommand returns the command with the passed name, or nil if
+// there is no such command.
+func (b *Bot) findCommand(name string) *chatCommand {
+	for _, cmd := range b.chatCommands {
+		for _, n := range cmd.names {
+			if strings.EqualFold(n, name) {
+				return cmd
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *Bot) printHelp(ctx context.Context, ev *slackevents.MessageEvent) {
+	match := b.regexps.Help.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	l := b.locale(ev.Channel)
+	if match[1] != "" {
+		cmd := b.findCommand(match[1])
+		if cmd == nil || !cmd.isEnabled(b) {
+			b.SendReply(l.Sprintf("there is no %q command. send `%s help` to list all commands.", match[1], b.trigger), ev)
+			return
+		}
+
+		b.SendReply(fmt.Sprintf("%s: %s\n%s", cmd.usageText(l), l.Sprintf(cmd.summary), cmd.help(b, l)), ev)
+		return
+	}
+
+	text := l.Sprintf("*karmabot commands*\n")
+	for _, cmd := range b.chatCommands {
+		if cmd.isEnabled(b) {
+			text += fmt.Sprintf("%s: %s\n", cmd.usageText(l), l.Sprintf(cmd.summary))
+		}
+	}
+
+	switch b.Config.ReplyType {
+	case "thread":
+		text += l.Sprintf("karmabot replies in a thread under your message.\n")
+	case "ephemeral":
+		text += l.Sprintf("karmabot's replies are only visible to you.\n")
+	}
+
+	text += l.Sprintf("send `%[1]s help <command>` to learn more about a command, e.g. `%[1]s help top`.", b.trigger)
+
+	b.SendReply(text, ev)
+}
+
+// webURL returns the URL of the web UI, or an empty string if the
+// web UI is disabled.
+func (b *Bot) webURL() string {
+	if b.Config.UI == nil {
+		return ""
+	}
+
+	url, err := b.Config.UI.GetURL("/")
+	if err != nil {
+		return ""
+	}
+
+	return url
+}
+
+// reactjiList formats reactjis for help texts.
+func reactjiList(reactjis StringList) string {
+	var formatted []string
+	for reactji := range reactjis {
+		formatted = append(formatted, fmt.Sprintf(":%s:", reactji))
+	}
+	sort.Strings(formatted)
+
+	return strings.Join(formatted, ", ")
+}
+
+// formatDuration formats a duration for help texts in the largest
+// whole unit that it can be expressed in.
+func formatDuration(l *locale, d time.Duration) string {
+	for _, unit := range []struct {
+		duration       time.Duration
+		single, plural string
+	}{
+		{24 * time.Hour, l.Sprintf("day"), l.Sprintf("%d days", int(d/(24*time.Hour)))},
+		{time.Hour, l.Sprintf("hour"), l.Sprintf("%d hours", int(d/time.Hour))},
+		{time.Minute, l.Sprintf("minute"), l.Sprintf("%d minutes", int(d/time.Minute))},
+	} {
+		if d%unit.duration != 0 {
+			continue
+		}
+
+		if d == unit.duration {
+			return unit.single
+		}
+
+		return unit.plural
+	}
+
+	return d.String()
+}
@@ -0,0 +1,135 @@
+package karmabot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestHelp(t *testing.T) {
+	b, cs, _ := newBot(&Config{
+		UI:               blankui.New(),
+		LeaderboardLimit: 10,
+		MaxPoints:        6,
+		UndoWindow:       5 * time.Minute,
+	})
+
+	for _, text := range []string{"karma help", "karma help top", "karma help undo", "karma help budget"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	if len(cs.SentMessages) != 4 {
+		t.Fatalf("sent messages %+v; want 4 messages", cs.SentMessages)
+	}
+
+	list := cs.SentMessages[0].Text
+	for _, want := range []string{"`karma top [n]", "`karma undo`", "`karma help [command]`", "`<user>==`"} {
+		if !strings.Contains(list, want) {
+			t.Errorf("help %q does not list %s", list, want)
+		}
+	}
+	for _, disabled := range []string{"`karma budget`", "`?m <user>`", "react to a message"} {
+		if strings.Contains(list, disabled) {
+			t.Errorf("help %q lists the disabled command %s", list, disabled)
+		}
+	}
+
+	for i, want := range []string{
+		"lists the top 10 users",
+		"within the last 5 minutes",
+		`there is no "budget" command`,
+	} {
+		if msg := cs.SentMessages[i+1].Text; !strings.Contains(msg, want) {
+			t.Errorf("sent help %q; want it to contain %q", msg, want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Minute:      "minute",
+		5 * time.Minute:  "5 minutes",
+		2 * time.Hour:    "2 hours",
+		24 * time.Hour:   "day",
+		72 * time.Hour:   "3 days",
+		90 * time.Second: "1m30s",
+	}
+
+	for d, want := range tests {
+		if got := formatDuration(locales[defaultLocale], d); got != want {
+			t.Errorf("formatDuration(%s) = %q; want %q", d, got, want)
+		}
+	}
+}
+
+func TestUsageText(t *testing.T) {
+	commands := newChatCommands("kb", newBotRegexps([]string{"kb"}, "UBOT"))
+
+	tests := map[string]map[string]string{
+		"motivate": {
+			"en": "`?m <user>` or `!m <user>`",
+			"de": "`?m <user>` oder `!m <user>`",
+		},
+		"top": {
+			"en": "`kb top [n] [people|things] [here] [period] [raw]`",
+			"de": "`kb top [n] [people|things] [here] [period] [raw]`",
+		},
+		"reactji": {
+			"en": "react to a message",
+			"de": "auf eine Nachricht reagieren",
+		},
+	}
+
+	for _, cmd := range commands {
+		for name, want := range tests[cmd.names[0]] {
+			if got := cmd.usageText(locales[name]); got != want {
+				t.Errorf("usage of %s in %s = %q; want %q", cmd.names[0], name, got, want)
+			}
+		}
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	b, cs, _ := newBot(&Config{
+		UI:               blankui.New(),
+		LeaderboardLimit: 10,
+		Triggers:         []string{"kb"},
+		BotUserID:        "UBOT",
+	})
+
+	for _, text := range []string{"karma top 1", "kb top 1", "<@UBOT> top 1", "kb help nothing"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	want := []string{
+		"*top 1 leaderboard*\n1. önehundred_points == 100\n",
+		"*top 1 leaderboard*\n1. önehundred_points == 100\n",
+		"there is no \"nothing\" command. send `kb help` to list all commands.",
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent messages %+v; want %d messages", cs.SentMessages, len(want))
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Text != want[i] {
+			t.Errorf("sent message %q; want %q", msg.Text, want[i])
+		}
+	}
+
+	if got := b.commandText("top 5"); got != "kb top 5" {
+		t.Errorf("commandText(%q) = %q; want %q", "top 5", got, "kb top 5")
+	}
+}
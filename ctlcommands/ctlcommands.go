@@ -1,7 +1,9 @@
 package ctlcommands
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/kamaln7/karmabot/database"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/aybabtme/log"
 	"github.com/pquerna/otp/totp"
+	"github.com/slack-go/slack"
 	"github.com/urfave/cli"
 )
 
@@ -17,7 +20,7 @@ type Commands struct {
 }
 
 func (cc *Commands) Serve(c *cli.Context) error {
-	db := cc.getDB(c.String("db"))
+	db := cc.getDB(c)
 	TOTP := c.String("totp")
 
 	ui, err := webui.New(&webui.Config{
@@ -61,7 +64,8 @@ func (cc *Commands) Mktotp(c *cli.Context) error {
 
 func (cc *Commands) AddKarma(c *cli.Context) error {
 	var (
-		db     = cc.getDB(c.String("db"))
+		ctx    = context.Background()
+		db     = cc.getDB(c)
 		from   = c.String("from")
 		to     = c.String("to")
 		reason = c.String("reason")
@@ -76,14 +80,18 @@ func (cc *Commands) AddKarma(c *cli.Context) error {
 		cc.Logger.Fatal("you may not add 0 points to a user")
 	}
 
+	from = cc.resolveUser(ctx, db, from)
+	to, kind := cc.resolveTarget(ctx, db, to)
+
 	record := &database.Points{
 		From:   from,
 		To:     to,
 		Reason: reason,
 		Points: points,
+		Kind:   kind,
 	}
 
-	err := db.InsertPoints(record)
+	err := db.InsertPoints(ctx, record)
 	if err != nil {
 		cc.Logger.Err(err).Fatal("could not insert record")
 	}
@@ -95,7 +103,8 @@ func (cc *Commands) AddKarma(c *cli.Context) error {
 
 func (cc *Commands) MigrateKarma(c *cli.Context) error {
 	var (
-		db   = cc.getDB(c.String("db"))
+		ctx  = context.Background()
+		db   = cc.getDB(c)
 		from = c.String("from")
 		to   = c.String("to")
 	)
@@ -104,7 +113,12 @@ func (cc *Commands) MigrateKarma(c *cli.Context) error {
 		cc.Logger.Fatal("please pass valid users to the `to` and `from` options")
 	}
 
-	user, err := db.GetUser(from)
+	var (
+		fromID, fromKind = cc.resolveTarget(ctx, db, from)
+		toID, toKind     = cc.resolveTarget(ctx, db, to)
+	)
+
+	user, err := db.GetUser(ctx, fromID, nil)
 	if err != nil {
 		cc.Logger.Err(err).KV("from", from).Fatal("could not look up user `from`")
 	}
@@ -117,22 +131,24 @@ func (cc *Commands) MigrateKarma(c *cli.Context) error {
 	records := []*database.Points{
 		// remove points from `from`
 		{
-			From:   "karmabot",
-			To:     from,
+			From:   database.SystemGiver,
+			To:     fromID,
 			Reason: reason,
 			Points: -user.Points,
+			Kind:   fromKind,
 		},
 		// add points to `to`
 		{
-			From:   "karmabot",
-			To:     to,
+			From:   database.SystemGiver,
+			To:     toID,
 			Reason: reason,
 			Points: user.Points,
+			Kind:   toKind,
 		},
 	}
 
 	for _, record := range records {
-		err := db.InsertPoints(record)
+		err := db.InsertPoints(ctx, record)
 		if err != nil {
 			cc.Logger.Err(err).Fatal("could not insert record")
 		}
@@ -145,24 +161,27 @@ func (cc *Commands) MigrateKarma(c *cli.Context) error {
 
 func (cc *Commands) ResetKarma(c *cli.Context) error {
 	var (
-		db   = cc.getDB(c.String("db"))
+		ctx  = context.Background()
+		db   = cc.getDB(c)
 		name = c.String("user")
 	)
 
 	if name == "" {
 		cc.Logger.Fatal("please pass a valid user to the `user` option")
 	}
+	id, kind := cc.resolveTarget(ctx, db, name)
 
-	user, err := db.GetUser(name)
+	user, err := db.GetUser(ctx, id, nil)
 	if err != nil {
 		cc.Logger.Err(err).KV("user", name).Fatal("could not look up user")
 	}
 
-	err = db.InsertPoints(&database.Points{
-		From:   "karmabot",
-		To:     name,
+	err = db.InsertPoints(ctx, &database.Points{
+		From:   database.SystemGiver,
+		To:     id,
 		Points: -1 * user.Points,
 		Reason: "karmabotctl resetting karma",
+		Kind:   kind,
 	})
 
 	if err != nil {
@@ -176,7 +195,8 @@ func (cc *Commands) ResetKarma(c *cli.Context) error {
 
 func (cc *Commands) SetKarma(c *cli.Context) error {
 	var (
-		db     = cc.getDB(c.String("db"))
+		ctx    = context.Background()
+		db     = cc.getDB(c)
 		name   = c.String("user")
 		points = c.Int("points")
 	)
@@ -184,17 +204,19 @@ func (cc *Commands) SetKarma(c *cli.Context) error {
 	if name == "" {
 		cc.Logger.Fatal("please pass a valid user to the `user` option")
 	}
+	id, kind := cc.resolveTarget(ctx, db, name)
 
-	user, err := db.GetUser(name)
+	user, err := db.GetUser(ctx, id, nil)
 	if err != nil {
 		cc.Logger.Err(err).KV("user", name).Fatal("could not look up user")
 	}
 
-	err = db.InsertPoints(&database.Points{
-		From:   "karmabot",
-		To:     name,
+	err = db.InsertPoints(ctx, &database.Points{
+		From:   database.SystemGiver,
+		To:     id,
 		Points: points - user.Points,
 		Reason: "karmabotctl overriding karma",
+		Kind:   kind,
 	})
 
 	if err != nil {
@@ -208,15 +230,16 @@ func (cc *Commands) SetKarma(c *cli.Context) error {
 
 func (cc *Commands) GetThrowback(c *cli.Context) error {
 	var (
+		ctx  = context.Background()
 		user = c.String("user")
-		db   = cc.getDB(c.String("db"))
+		db   = cc.getDB(c)
 	)
 
 	if user == "" {
 		cc.Logger.Fatal("please pass a valid user to the `user` option")
 	}
 
-	throwback, err := db.GetThrowback(user)
+	throwback, err := db.GetThrowback(ctx, cc.resolveUser(ctx, db, user))
 	if err != nil {
 		cc.Logger.Err(err).Fatal("could not look up user data")
 	}
@@ -225,13 +248,182 @@ func (cc *Commands) GetThrowback(c *cli.Context) error {
 	return nil
 }
 
-func (cc *Commands) getDB(path string) *database.DB {
-	db, err := database.New(&database.Config{
-		Path: path,
+func (cc *Commands) GetHistory(c *cli.Context) error {
+	var (
+		ctx    = context.Background()
+		user   = c.String("user")
+		limit  = c.Int("limit")
+		offset = c.Int("offset")
+		db     = cc.getDB(c)
+	)
+
+	if user == "" {
+		cc.Logger.Fatal("please pass a valid user to the `user` option")
+	}
+
+	history, err := db.GetHistory(ctx, cc.resolveUser(ctx, db, user), limit, offset)
+	if err != nil {
+		cc.Logger.Err(err).Fatal("could not look up user data")
+	}
+
+	for _, record := range history {
+		cc.Logger.KV("from", record.From).KV("points", record.Points.Points).KV("reason", record.Reason).KV("timestamp", record.Timestamp).Info("karma operation")
+	}
+
+	return nil
+}
+
+func (cc *Commands) BackfillUserIDs(c *cli.Context) error {
+	var (
+		ctx   = context.Background()
+		db    = cc.getDB(c)
+		token = c.String("bottoken")
+	)
+
+	if token == "" {
+		cc.Logger.Fatal("please pass a slack Bot token to the `bottoken` option")
+	}
+
+	users, err := slack.New(token).GetUsers()
+	if err != nil {
+		cc.Logger.Err(err).Fatal("could not list slack users")
+	}
+
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+
+	updated, err := db.BackfillUserIDs(ctx, names)
+	if err != nil {
+		cc.Logger.Err(err).Fatal("could not backfill user IDs")
+	}
+
+	cc.Logger.KV("users", len(names)).KV("records", updated).Info("backfilled user IDs")
+	return nil
+}
+
+func (cc *Commands) AssignTeam(c *cli.Context) error {
+	var (
+		ctx = context.Background()
+		db  = cc.openDB(&database.Config{
+			DSN: c.String("db"),
+		})
+		team = c.String("team")
+	)
+
+	if team == "" {
+		cc.Logger.Fatal("please pass a slack team ID to the `team` option")
+	}
+
+	moved, err := db.AssignTeam(ctx, team)
+	if err != nil {
+		cc.Logger.Err(err).Fatal("could not assign records to team")
+	}
+
+	cc.Logger.KV("team", team).KV("records", moved).Info("assigned records to team")
+	return nil
+}
+
+func (cc *Commands) RebuildTotals(c *cli.Context) error {
+	db := cc.openDB(&database.Config{
+		DSN: c.String("db"),
 	})
 
+	err := db.RebuildTotals(context.Background())
+	if err != nil {
+		cc.Logger.Err(err).Fatal("could not rebuild totals")
+	}
+
+	cc.Logger.Info("rebuilt totals")
+	return nil
+}
+
+func (cc *Commands) Migrate(c *cli.Context) error {
+	ctx := context.Background()
+	db := cc.getDBWithoutMigrations(c.String("db"))
+
+	applied, err := db.Migrate(ctx)
+	for _, m := range applied {
+		cc.Logger.KV("migration", m.Version).KV("name", m.Name).Info("applied migration")
+	}
+	if err != nil {
+		cc.Logger.Err(err).Fatal("could not apply migrations")
+	}
+
+	cc.Logger.KV("applied", len(applied)).Info("database is up to date")
+	return nil
+}
+
+func (cc *Commands) MigrationStatus(c *cli.Context) error {
+	ctx := context.Background()
+	db := cc.getDBWithoutMigrations(c.String("db"))
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		cc.Logger.Err(err).Fatal("could not look up migrations")
+	}
+
+	for _, m := range statuses {
+		ll := cc.Logger.KV("migration", m.Version).KV("name", m.Name).KV("applied", m.Applied)
+		if m.Applied {
+			ll = ll.KV("applied_at", m.AppliedAt)
+		}
+
+		ll.Info("migration")
+	}
+
+	return nil
+}
+
+// resolveUser returns the Slack user ID of a cached username,
+// or the lowercased name for anything else.
+func (cc *Commands) resolveUser(ctx context.Context, db *database.DB, name string) string {
+	id, _ := cc.resolveTarget(ctx, db, name)
+	return id
+}
+
+// resolveTarget returns what the karma of the passed name is stored
+// under along with its kind. Cached usernames are Slack users and
+// anything else is a thing, which is lowercased like karmabot does.
+func (cc *Commands) resolveTarget(ctx context.Context, db *database.DB, name string) (string, string) {
+	id, err := db.GetUserID(ctx, name)
+	switch err {
+	case nil:
+		return id, database.KindUser
+	case database.ErrNoSuchUser:
+		return strings.ToLower(name), database.KindThing
+	default:
+		cc.Logger.Err(err).KV("user", name).Fatal("could not look up user")
+		return "", ""
+	}
+}
+
+// getDB opens the database and scopes it to the
+// team passed to the `team` option.
+func (cc *Commands) getDB(c *cli.Context) *database.DB {
+	db := cc.openDB(&database.Config{
+		DSN: c.String("db"),
+	})
+
+	return db.WithTeam(c.String("team"))
+}
+
+func (cc *Commands) getDBWithoutMigrations(dsn string) *database.DB {
+	return cc.openDB(&database.Config{
+		DSN:            dsn,
+		SkipMigrations: true,
+	})
+}
+
+func (cc *Commands) openDB(config *database.Config) *database.DB {
+	config.Log = cc.Logger
+	dsn := config.DSN
+
+	db, err := database.New(config)
+
 	if err != nil {
-		cc.Logger.KV("path", path).Err(err).Fatal("could not open sqlite db")
+		cc.Logger.KV("db", dsn).Err(err).Fatal("could not open database")
 	}
 
 	return db
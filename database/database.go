@@ -1,37 +1,62 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/aybabtme/log"
 
-	// import the sqlite3 driver
-	_ "github.com/mattn/go-sqlite3"
+	// import the postgres driver
+	_ "github.com/lib/pq"
 )
 
 // Config contains the necessary config options to
-// connect to an sqlite3 database.
+// connect to a database. DSN is either a path to an
+// sqlite3 database or a postgres:// URL. Pending schema
+// migrations are applied on Init unless SkipMigrations
+// is set. Timeout limits the duration of every query made
+// by karmabot; maintenance operations are not limited.
 type Config struct {
-	Path string
-	Log  *log.Log
+	DSN            string
+	Log            *log.Log
+	SkipMigrations bool
+	Timeout        time.Duration
 }
 
 // A DB in an instance of a karmabot database.
 type DB struct {
 	Config *Config
 	SQL    *sql.DB
+
+	dialect *dialect
+	team    string
 }
 
 // Points is a karma record containing info about
-// a karma operation.
+// a karma operation. Kind is either KindUser or
+// KindThing, depending on the recipient. Channel is
+// the ID of the Slack channel that the operation was
+// performed in, if any.
 type Points struct {
 	From, To, Reason string
 	Points           int
+	Kind             string
+	Channel          string
 }
 
+// The kinds of karma recipients.
+const (
+	// KindUser is the kind of Slack users.
+	KindUser = "user"
+
+	// KindThing is the kind of anything that is not a Slack user.
+	KindThing = "thing"
+)
+
 // Throwback is a karma operation that has happened
 type Throwback struct {
 	Points
@@ -48,10 +73,36 @@ type User struct {
 	Points int
 }
 
+// Givers lists the top X users who have given the most points.
+type Givers []*Giver
+
+// A Giver is an entry in Givers. Operations is the number of
+// karma operations in which the user has given points.
+type Giver struct {
+	Name       string
+	Operations int
+	Points     int
+}
+
+// SystemGiver is the giver of the karma operations that are
+// made with karmabotctl rather than by Slack users.
+const SystemGiver = "karmabot"
+
+// timeFormat is the format in which timestamps are stored.
+const timeFormat = "2006-01-02 15:04:05"
+
 // ErrNoSuchUser is returned when a user lookup
 // is performed on a non-existent user
 var ErrNoSuchUser = errors.New("no such user")
 
+// ErrNoSuchChannel is returned when a channel lookup
+// is performed on a non-existent channel
+var ErrNoSuchChannel = errors.New("no such channel")
+
+// ErrNoSuchRecord is returned when there is no karma
+// record matching a lookup
+var ErrNoSuchRecord = errors.New("no such karma operation")
+
 // New returns a new instance of a karmabot database
 // and initializes it
 func New(config *Config) (*DB, error) {
@@ -68,86 +119,311 @@ func New(config *Config) (*DB, error) {
 	return instance, nil
 }
 
-// Init initializes an sqlite3 database in order
-// for karmabot to be able to use it
+// Init initializes an sqlite3 or postgres database in
+// order for karmabot to be able to use it
 func (db *DB) Init() error {
-	sqlite, err := sql.Open("sqlite3", db.Config.Path)
+	db.dialect = dialectFor(db.Config.DSN)
+
+	conn, err := sql.Open(db.dialect.sqlDriver, db.Config.DSN)
 
 	if err != nil {
 		return err
 	}
 
-	db.SQL = sqlite
-	return db.createTable()
+	db.SQL = conn
+
+	if db.Config.SkipMigrations {
+		return nil
+	}
+
+	applied, err := db.Migrate(context.Background())
+	if db.Config.Log != nil {
+		for _, m := range applied {
+			db.Config.Log.KV("migration", m.Version).KV("name", m.Name).Info("applied database migration")
+		}
+	}
+
+	return err
 }
 
-func (db *DB) createTable() error {
-	schema := strings.Replace(
-		`create table if not exists karma (
-			^id^ integer primary key,
-			^from^ text not null,
-			^to^ text not null,
-			^points^ integer not null,
-			^reason^ text,
-			^timestamp^ text not null default (datetime('now'))
-		)`,
-		"^", "`", -1)
+// withTimeout applies the configured query timeout to ctx.
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.Config.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, db.Config.Timeout)
+}
 
-	_, err := db.SQL.Exec(schema)
+// WithTeam returns a copy of the DB that shares its connection
+// but only reads and writes records that belong to the passed
+// Slack team. Single-workspace installations use the empty team.
+func (db *DB) WithTeam(team string) *DB {
+	scoped := *db
+	scoped.team = team
+
+	return &scoped
+}
+
+// AssignTeam moves all records that do not belong to any team
+// to the passed team. It returns the number of moved records.
+func (db *DB) AssignTeam(ctx context.Context, team string) (int64, error) {
+	tx, err := db.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, db.dialect.rebind("update karma set `team` = ? where `team` = ''"), team)
+	if err != nil {
+		return 0, err
+	}
+
+	// cached names that the team already has are newer than
+	// the ones that do not belong to any team
+	for _, table := range []string{"users", "channels"} {
+		_, err = tx.ExecContext(ctx, db.dialect.rebind("delete from "+table+" where `team` = '' and `id` in (select `id` from "+table+" where `team` = ?)"), team)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, db.dialect.rebind("update "+table+" set `team` = ? where `team` = ''"), team)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = db.rebuildTotals(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, tx.Commit()
+}
+
+// RebuildTotals recomputes the points totals of all users
+// in all teams from the karma records.
+func (db *DB) RebuildTotals(ctx context.Context) error {
+	tx, err := db.SQL.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = db.SQL.Exec("create index if not exists idx_to on karma(`to`);")
+	err = db.rebuildTotals(ctx, tx)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-// InsertPoints inserts a Points object into the database.
-func (db *DB) InsertPoints(points *Points) error {
-	stmt, err := db.SQL.Prepare("insert into karma (`from`, `to`, `reason`, `points`) values(?, ?, ?, ?)")
-
+func (db *DB) rebuildTotals(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "delete from user_totals")
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(points.From, points.To, points.Reason, points.Points)
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("insert into user_totals (`team`, `name`, `points`, `operations`, `abs_points`) select `team`, `to`, sum(`points`), count(*), sum(abs(`points`)) from karma where `revoked_at` is null group by `team`, `to`"))
 
 	return err
 }
 
-// GetUser returns info about a user.
-func (db *DB) GetUser(name string) (*User, error) {
-	stmt, err := db.SQL.Prepare("select count(`to`) as `count` from karma where `to` = ?")
+// InsertPoints inserts a Points object into the database
+// and updates the recipient's totals.
+func (db *DB) InsertPoints(ctx context.Context, points *Points) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.SQL.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("insert into karma (`team`, `from`, `to`, `reason`, `points`, `kind`, `channel`) values(?, ?, ?, ?, ?, ?, ?)"), db.team, points.From, points.To, points.Reason, points.Points, points.Kind, points.Channel)
+	if err != nil {
+		return err
 	}
-	defer stmt.Close()
 
-	user := &User{
-		Name: name,
+	abs := points.Points
+	if abs < 0 {
+		abs = -abs
 	}
 
-	var userExists int
-	err = stmt.QueryRow(user.Name).Scan(&userExists)
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("insert into user_totals (`team`, `name`, `points`, `operations`, `abs_points`) values (?, ?, ?, 1, ?) on conflict (`team`, `name`) do update set `points` = user_totals.`points` + excluded.`points`, `operations` = user_totals.`operations` + 1, `abs_points` = user_totals.`abs_points` + excluded.`abs_points`"), db.team, points.To, points.Points, abs)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if userExists == 0 {
-		return nil, ErrNoSuchUser
+
+	return tx.Commit()
+}
+
+// SetUserName caches the username of the Slack user with the
+// passed ID. Cached usernames are shown instead of IDs.
+func (db *DB) SetUserName(ctx context.Context, id, name string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := db.SQL.ExecContext(ctx, db.dialect.rebind("insert into users (`id`, `team`, `name`) values (?, ?, ?) on conflict (`team`, `id`) do update set `name` = excluded.`name`"), id, db.team, name)
+
+	return err
+}
+
+// GetUserID returns the ID of the Slack user with the passed
+// username, provided that it has been cached before.
+func (db *DB) GetUserID(ctx context.Context, name string) (string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id string
+	err := db.SQL.QueryRowContext(ctx, db.dialect.rebind("select `id` from users where `team` = ? and lower(`name`) = lower(?) limit 1"), db.team, name).Scan(&id)
+	switch err {
+	case nil:
+		return id, nil
+	case sql.ErrNoRows:
+		return "", ErrNoSuchUser
+	default:
+		return "", err
 	}
+}
+
+// SetChannelName caches the name of the Slack channel with the
+// passed ID so that channels can be looked up by name.
+func (db *DB) SetChannelName(ctx context.Context, id, name string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
 
-	stmt, err = db.SQL.Prepare("select sum(`points`) as `points` from karma where `to` = ?")
+	_, err := db.SQL.ExecContext(ctx, db.dialect.rebind("insert into channels (`id`, `team`, `name`) values (?, ?, ?) on conflict (`team`, `id`) do update set `name` = excluded.`name`"), id, db.team, name)
+
+	return err
+}
+
+// GetChannelID returns the ID of the Slack channel with the passed
+// name, provided that it has been cached before.
+func (db *DB) GetChannelID(ctx context.Context, name string) (string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var id string
+	err := db.SQL.QueryRowContext(ctx, db.dialect.rebind("select `id` from channels where `team` = ? and lower(`name`) = lower(?) limit 1"), db.team, name).Scan(&id)
+	switch err {
+	case nil:
+		return id, nil
+	case sql.ErrNoRows:
+		return "", ErrNoSuchChannel
+	default:
+		return "", err
+	}
+}
+
+// BackfillUserIDs replaces lowercased usernames in existing karma
+// records with the matching Slack user IDs and caches the usernames.
+// Records on the replaced usernames are marked as karma on users.
+// users maps Slack user IDs to usernames. It returns the number of
+// updated records.
+func (db *DB) BackfillUserIDs(ctx context.Context, users map[string]string) (int64, error) {
+	tx, err := db.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var updated int64
+	for id, name := range users {
+		_, err = tx.ExecContext(ctx, db.dialect.rebind("insert into users (`id`, `team`, `name`) values (?, ?, ?) on conflict (`team`, `id`) do update set `name` = excluded.`name`"), id, db.team, name)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, query := range []string{
+			"update karma set `from` = ? where `team` = ? and `from` = ?",
+			"update karma set `to` = ?, `kind` = '" + KindUser + "' where `team` = ? and `to` = ?",
+		} {
+			res, err := tx.ExecContext(ctx, db.dialect.rebind(query), id, db.team, strings.ToLower(name))
+			if err != nil {
+				return 0, err
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return 0, err
+			}
+			updated += n
+		}
+	}
+
+	err = db.rebuildTotals(ctx, tx)
 	if err != nil {
+		return 0, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// displayName returns the cached username of a Slack user ID, or
+// the passed string itself if it does not belong to a known user.
+func (db *DB) displayName(ctx context.Context, id string) (string, error) {
+	var name string
+	err := db.SQL.QueryRowContext(ctx, db.dialect.rebind("select `name` from users where `team` = ? and `id` = ?"), db.team, id).Scan(&name)
+	switch err {
+	case nil:
+		return name, nil
+	case sql.ErrNoRows:
+		return id, nil
+	default:
+		return "", err
+	}
+}
+
+// GetUser returns info about a user. Its name is the cached
+// username if the user is a known Slack user. Users' points are
+// computed from the karma records that match the filter.
+func (db *DB) GetUser(ctx context.Context, name string, filter *Filter) (*User, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if filter != nil && filter.HalfLife > 0 {
+		leaderboard, err := db.decayedLeaderboard(ctx, filter, 1, " and karma.`to` = ?", name)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(leaderboard) == 0 {
+			return nil, ErrNoSuchUser
+		}
+
+		return leaderboard[0], nil
+	}
+
+	var (
+		user = &User{}
+		err  error
+	)
+	if filter.IsZero() {
+		err = db.SQL.QueryRowContext(ctx, db.dialect.rebind("select `points` from user_totals where `team` = ? and `name` = ?"), db.team, name).Scan(&user.Points)
+	} else {
+		var records int
+		conds, args := filter.where()
+		err = db.SQL.QueryRowContext(ctx, db.dialect.rebind("select count(*), coalesce(sum(karma.`points`), 0) from karma where karma.`team` = ? and karma.`to` = ? and karma.`revoked_at` is null"+conds), append([]interface{}{db.team, name}, args...)...).Scan(&records, &user.Points)
+		if err == nil && records == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, ErrNoSuchUser
+	default:
 		return nil, err
 	}
-	defer stmt.Close()
 
-	err = stmt.QueryRow(user.Name).Scan(&user.Points)
+	user.Name, err = db.displayName(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -156,8 +432,27 @@ func (db *DB) GetUser(name string) (*User, error) {
 }
 
 // GetLeaderboard returns the leaderboard with the top X users.
-func (db *DB) GetLeaderboard(limit int) (Leaderboard, error) {
-	rows, err := db.SQL.Query("select `to`, sum(`points`) as `points` from karma group by `to` order by `points` desc limit ?", limit)
+// Leaderboards for filtered records are computed from the karma
+// records rather than the users' totals.
+func (db *DB) GetLeaderboard(ctx context.Context, limit int, filter *Filter) (Leaderboard, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if filter != nil && filter.HalfLife > 0 {
+		return db.decayedLeaderboard(ctx, filter, limit, "")
+	}
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if filter.IsZero() {
+		rows, err = db.SQL.QueryContext(ctx, db.dialect.rebind("select coalesce(users.`name`, user_totals.`name`), user_totals.`points` from user_totals left join users on users.`team` = user_totals.`team` and users.`id` = user_totals.`name` where user_totals.`team` = ? order by user_totals.`points` desc limit ?"), db.team, limit)
+	} else {
+		conds, args := filter.where()
+		args = append(append([]interface{}{db.team}, args...), limit)
+		rows, err = db.SQL.QueryContext(ctx, db.dialect.rebind("select coalesce(users.`name`, karma.`to`), sum(karma.`points`) as `points` from karma left join users on users.`team` = karma.`team` and users.`id` = karma.`to` where karma.`team` = ? and karma.`revoked_at` is null"+conds+" group by karma.`to`, users.`name` order by `points` desc limit ?"), args...)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -175,14 +470,128 @@ func (db *DB) GetLeaderboard(limit int) (Leaderboard, error) {
 		leaderboard = append(leaderboard, user)
 	}
 
-	return leaderboard, nil
+	return leaderboard, rows.Err()
+}
+
+// GetRank returns the position of a user on the leaderboard for the
+// records that match the filter. Users with the same points share a
+// rank.
+func (db *DB) GetRank(ctx context.Context, name string, filter *Filter) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	user, err := db.GetUser(ctx, name, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var ahead int
+	switch {
+	case filter != nil && filter.HalfLife > 0:
+		// decayed points are rounded to the nearest integer, so
+		// users whose points round to more than the user's are
+		// the ones with at least half a point more
+		decayed, decayedArgs := db.decayedPoints(filter)
+		conds, args := filter.where()
+		args = append(append(append(decayedArgs, db.team), args...), float64(user.Points)+0.5)
+		err = db.SQL.QueryRowContext(ctx, db.dialect.rebind("select count(*) from (select sum("+decayed+") as `points` from karma where karma.`team` = ? and karma.`revoked_at` is null"+conds+" group by karma.`to`) as totals where totals.`points` >= ?"), args...).Scan(&ahead)
+	case filter.IsZero():
+		err = db.SQL.QueryRowContext(ctx, db.dialect.rebind("select count(*) from user_totals where `team` = ? and `points` > ?"), db.team, user.Points).Scan(&ahead)
+	default:
+		conds, args := filter.where()
+		args = append(append([]interface{}{db.team}, args...), user.Points)
+		err = db.SQL.QueryRowContext(ctx, db.dialect.rebind("select count(*) from (select sum(karma.`points`) as `points` from karma where karma.`team` = ? and karma.`revoked_at` is null"+conds+" group by karma.`to`) as totals where totals.`points` > ?"), args...).Scan(&ahead)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return ahead + 1, nil
+}
+
+// decayedPoints returns an expression for the points of a karma
+// record weighted by the filter's HalfLife, along with its
+// arguments.
+func (db *DB) decayedPoints(filter *Filter) (string, []interface{}) {
+	return "karma.`points` * " + db.dialect.decay, []interface{}{time.Now().UTC().Format(timeFormat), filter.HalfLife.Seconds()}
+}
+
+// decayedLeaderboard returns the top X users in the karma records
+// that match the filter and the extra conditions, with their points
+// weighted by the filter's HalfLife.
+func (db *DB) decayedLeaderboard(ctx context.Context, filter *Filter, limit int, conds string, args ...interface{}) (Leaderboard, error) {
+	decayed, decayedArgs := db.decayedPoints(filter)
+	filterConds, filterArgs := filter.where()
+	args = append(append(append(append(decayedArgs, db.team), filterArgs...), args...), limit)
+
+	rows, err := db.SQL.QueryContext(ctx, db.dialect.rebind("select coalesce(users.`name`, karma.`to`), sum("+decayed+") as `points` from karma left join users on users.`team` = karma.`team` and users.`id` = karma.`to` where karma.`team` = ? and karma.`revoked_at` is null"+filterConds+conds+" group by karma.`to`, users.`name` order by `points` desc limit ?"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leaderboard Leaderboard
+	for rows.Next() {
+		var (
+			user   = &User{}
+			points float64
+		)
+		err := rows.Scan(&user.Name, &points)
+		if err != nil {
+			return nil, err
+		}
+
+		user.Points = int(math.Round(points))
+		leaderboard = append(leaderboard, user)
+	}
+
+	return leaderboard, rows.Err()
+}
+
+// GetGivers returns the top X users who have given the most points.
+// Only karma operations that gave positive points are counted, and
+// operations made with karmabotctl are left out.
+func (db *DB) GetGivers(ctx context.Context, limit int) (Givers, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.SQL.QueryContext(ctx, db.dialect.rebind("select coalesce(users.`name`, karma.`from`), count(*) as `operations`, sum(karma.`points`) as `points` from karma left join users on users.`team` = karma.`team` and users.`id` = karma.`from` where karma.`team` = ? and karma.`from` <> ? and karma.`revoked_at` is null and karma.`points` > 0 group by karma.`from`, users.`name` order by `points` desc, `operations` desc limit ?"), db.team, SystemGiver, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var givers Givers
+	for rows.Next() {
+		giver := &Giver{}
+		err := rows.Scan(&giver.Name, &giver.Operations, &giver.Points)
+		if err != nil {
+			return nil, err
+		}
+
+		givers = append(givers, giver)
+	}
+
+	return givers, rows.Err()
 }
 
 // GetTotalPoints returns the amount of points given or taken
 // for all users.
-func (db *DB) GetTotalPoints() (int, error) {
-	var res int
-	err := db.SQL.QueryRow("select sum(abs(`points`)) from karma").Scan(&res)
+func (db *DB) GetTotalPoints(ctx context.Context, filter *Filter) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var (
+		res int
+		err error
+	)
+
+	if filter.IsZero() {
+		err = db.SQL.QueryRowContext(ctx, db.dialect.rebind("select coalesce(sum(`abs_points`), 0) from user_totals where `team` = ?"), db.team).Scan(&res)
+	} else {
+		conds, args := filter.where()
+		err = db.SQL.QueryRowContext(ctx, db.dialect.rebind("select coalesce(sum(abs(karma.`points`)), 0) from karma where karma.`team` = ? and karma.`revoked_at` is null"+conds), append([]interface{}{db.team}, args...)...).Scan(&res)
+	}
 
 	if err != nil {
 		return 0, err
@@ -191,14 +600,51 @@ func (db *DB) GetTotalPoints() (int, error) {
 	return res, nil
 }
 
-// GetThrowback returns a random karma operation on a specific user
-func (db *DB) GetThrowback(user string) (*Throwback, error) {
+// GetPointsGiven returns the number of points that a user has
+// given or taken at or after since. Downvotes count towards the
+// number of points as well, but points given to and taken from the
+// same recipient cancel each other out, so that removing a reactji
+// gives the points back.
+func (db *DB) GetPointsGiven(ctx context.Context, from string, since time.Time) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var points int
+	err := db.SQL.QueryRowContext(ctx, db.dialect.rebind("select coalesce(sum(abs(given.`points`)), 0) from (select sum(`points`) as `points` from karma where `team` = ? and `from` = ? and `revoked_at` is null and `timestamp` >= ? group by `to`) as given"), db.team, from, since.UTC().Format(timeFormat)).Scan(&points)
+	if err != nil {
+		return 0, err
+	}
+
+	return points, nil
+}
+
+// GetPointsGivenTo returns the net number of points that a user
+// has given to a recipient at or after since.
+func (db *DB) GetPointsGivenTo(ctx context.Context, from, to string, since time.Time) (int, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var points int
+	err := db.SQL.QueryRowContext(ctx, db.dialect.rebind("select coalesce(sum(`points`), 0) from karma where `team` = ? and `from` = ? and `to` = ? and `revoked_at` is null and `timestamp` >= ?"), db.team, from, to, since.UTC().Format(timeFormat)).Scan(&points)
+	if err != nil {
+		return 0, err
+	}
+
+	return points, nil
+}
+
+// GetThrowback returns a random karma operation on a specific user.
+// Known Slack users are referred to by their cached usernames.
+func (db *DB) GetThrowback(ctx context.Context, user string) (*Throwback, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	var (
 		record    = &Throwback{}
 		timestamp = ""
 	)
 
-	err := db.SQL.QueryRow("select `from`, `to`, `reason`, `points`, `timestamp` from karma where `to` = ? and `id` >= (abs(random()) % (select max(`id`) from karma)) limit 1", user).Scan(&record.From, &record.To, &record.Reason, &record.Points.Points, &timestamp)
+	err := db.SQL.QueryRowContext(ctx, db.dialect.rebind("select coalesce(fu.`name`, karma.`from`), coalesce(tu.`name`, karma.`to`), karma.`reason`, karma.`points`, karma.`kind`, karma.`channel`, karma.`timestamp` from karma left join users fu on fu.`team` = karma.`team` and fu.`id` = karma.`from` left join users tu on tu.`team` = karma.`team` and tu.`id` = karma.`to` where karma.`team` = ? and karma.`to` = ? and karma.`revoked_at` is null order by random() limit 1"), db.team, user).Scan(&record.From, &record.To, &record.Reason, &record.Points.Points, &record.Kind, &record.Channel, &timestamp)
 	switch err {
 	case nil:
 	case sql.ErrNoRows:
@@ -207,10 +653,128 @@ func (db *DB) GetThrowback(user string) (*Throwback, error) {
 		return nil, err
 	}
 
-	record.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestamp)
+	record.Timestamp, err = time.Parse(timeFormat, timestamp)
 	if err != nil {
 		return nil, err
 	}
 
 	return record, nil
 }
+
+// GetHistory returns the latest karma operations on a specific
+// user, newest first. Known Slack users are referred to by their
+// cached usernames.
+func (db *DB) GetHistory(ctx context.Context, user string, limit, offset int) ([]*Throwback, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.SQL.QueryContext(ctx, db.dialect.rebind("select coalesce(fu.`name`, karma.`from`), coalesce(tu.`name`, karma.`to`), karma.`reason`, karma.`points`, karma.`kind`, karma.`channel`, karma.`timestamp` from karma left join users fu on fu.`team` = karma.`team` and fu.`id` = karma.`from` left join users tu on tu.`team` = karma.`team` and tu.`id` = karma.`to` where karma.`team` = ? and karma.`to` = ? and karma.`revoked_at` is null order by karma.`timestamp` desc, karma.`id` desc limit ? offset ?"), db.team, user, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*Throwback
+	for rows.Next() {
+		var (
+			record    = &Throwback{}
+			timestamp string
+		)
+
+		err := rows.Scan(&record.From, &record.To, &record.Reason, &record.Points.Points, &record.Kind, &record.Channel, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		record.Timestamp, err = time.Parse(timeFormat, timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		history = append(history, record)
+	}
+
+	return history, rows.Err()
+}
+
+// GetReasons returns the X most frequent reasons for which a user
+// has received karma. Reasons are grouped by their normalized form.
+func (db *DB) GetReasons(ctx context.Context, user string, limit int) (Reasons, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.SQL.QueryContext(ctx, db.dialect.rebind("select karma.`reason`, count(*), sum(karma.`points`) from karma where karma.`team` = ? and karma.`to` = ? and karma.`revoked_at` is null and karma.`reason` <> '' group by karma.`reason`"), db.team, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counter := make(ReasonCounter)
+	for rows.Next() {
+		var (
+			reason             string
+			operations, points int
+		)
+		err := rows.Scan(&reason, &operations, &points)
+		if err != nil {
+			return nil, err
+		}
+
+		counter.Add(reason, operations, points)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counter.Top(limit), nil
+}
+
+// RevokeLast revokes the latest karma operation performed by a user
+// at or after since, and updates the recipient's totals. Revoked
+// records are kept in the database but ignored by all queries. It
+// returns the revoked record with the recipient's ID.
+func (db *DB) RevokeLast(ctx context.Context, from string, since time.Time) (*Points, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id     int64
+		record = &Points{From: from}
+	)
+	err = tx.QueryRowContext(ctx, db.dialect.rebind("select `id`, `to`, `reason`, `points`, `kind`, `channel` from karma where `team` = ? and `from` = ? and `revoked_at` is null and `timestamp` >= ? order by `id` desc limit 1"), db.team, from, since.UTC().Format(timeFormat)).Scan(&id, &record.To, &record.Reason, &record.Points, &record.Kind, &record.Channel)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, ErrNoSuchRecord
+	default:
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("update karma set `revoked_at` = ? where `id` = ?"), time.Now().UTC().Format(timeFormat), id)
+	if err != nil {
+		return nil, err
+	}
+
+	abs := record.Points
+	if abs < 0 {
+		abs = -abs
+	}
+
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("update user_totals set `points` = `points` - ?, `operations` = `operations` - 1, `abs_points` = `abs_points` - ? where `team` = ? and `name` = ?"), record.Points, abs, db.team, record.To)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("delete from user_totals where `team` = ? and `name` = ? and `operations` = 0"), db.team, record.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, tx.Commit()
+}
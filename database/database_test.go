@@ -0,0 +1,633 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDB returns a migrated sqlite3 database in a temporary
+// directory, and a function that removes it.
+func newTestDB(t *testing.T) (*DB, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "karmabot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := New(&Config{DSN: filepath.Join(dir, "karma.sqlite3")})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("New: %v", err)
+	}
+
+	return db, func() {
+		db.SQL.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// insertAt inserts a karma record with a specific timestamp, which
+// InsertPoints always sets to now, and rebuilds the totals.
+func insertAt(t *testing.T, db *DB, points *Points, timestamp time.Time) {
+	t.Helper()
+
+	_, err := db.SQL.Exec("insert into karma (`team`, `from`, `to`, `reason`, `points`, `kind`, `channel`, `timestamp`) values (?, ?, ?, ?, ?, ?, ?, ?)", db.team, points.From, points.To, points.Reason, points.Points, points.Kind, points.Channel, timestamp.UTC().Format(timeFormat))
+	if err != nil {
+		t.Fatalf("inserting a record: %v", err)
+	}
+
+	err = db.RebuildTotals(context.Background())
+	if err != nil {
+		t.Fatalf("db.RebuildTotals: %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("db.MigrationStatus: %v", err)
+	}
+	if len(statuses) != len(migrations) {
+		t.Fatalf("got %d migration statuses; want %d", len(statuses), len(migrations))
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("migration %d (%s) has not been applied", status.Version, status.Name)
+		}
+	}
+
+	applied, err := db.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("db.Migrate: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("db.Migrate applied %d migrations again; want none", len(applied))
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "karmabot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// databases created before migrations existed only have the
+	// karma table, without a schema_migrations table
+	dsn := filepath.Join(dir, "karma.sqlite3")
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"create table karma (`id` integer primary key, `from` text not null, `to` text not null, `points` integer not null, `reason` text, `timestamp` text not null default (datetime('now')))",
+		"insert into karma (`from`, `to`, `points`, `reason`) values ('bob', 'alice', 2, 'the launch')",
+		"insert into karma (`from`, `to`, `points`, `reason`) values ('carol', 'alice', -1, '')",
+		"insert into karma (`from`, `to`, `points`, `reason`) values ('alice', 'bob', 1, '')",
+	} {
+		_, err = conn.Exec(stmt)
+		if err != nil {
+			t.Fatalf("setting up the baseline database: %v", err)
+		}
+	}
+	conn.Close()
+
+	db, err := New(&Config{DSN: dsn})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.SQL.Close()
+
+	ctx := context.Background()
+	user, err := db.GetUser(ctx, "alice", nil)
+	if err != nil {
+		t.Fatalf("db.GetUser: %v", err)
+	}
+	if user.Points != 1 {
+		t.Errorf("alice has %d points; want 1", user.Points)
+	}
+
+	total, err := db.GetTotalPoints(ctx, nil)
+	if err != nil {
+		t.Fatalf("db.GetTotalPoints: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("total points are %d; want 4", total)
+	}
+
+	leaderboard, err := db.GetLeaderboard(ctx, 10, &Filter{Kind: KindThing})
+	if err != nil {
+		t.Fatalf("db.GetLeaderboard: %v", err)
+	}
+	if len(leaderboard) != 2 {
+		t.Errorf("leaderboard is %+v; want the existing records to be things", leaderboard)
+	}
+}
+
+func TestInsertPoints(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, p := range []*Points{
+		{From: "bob", To: "alice", Points: 3, Kind: KindThing},
+		{From: "bob", To: "alice", Points: -1, Kind: KindThing},
+		{From: "alice", To: "bob", Points: 2, Kind: KindThing},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	var points, operations, absPoints int
+	err := db.SQL.QueryRow("select `points`, `operations`, `abs_points` from user_totals where `team` = '' and `name` = 'alice'").Scan(&points, &operations, &absPoints)
+	if err != nil {
+		t.Fatalf("reading alice's totals: %v", err)
+	}
+	if points != 2 || operations != 2 || absPoints != 4 {
+		t.Errorf("alice's totals are %d points, %d operations and %d absolute points; want 2, 2 and 4", points, operations, absPoints)
+	}
+
+	// records in other teams are kept apart
+	err = db.WithTeam("T1").InsertPoints(ctx, &Points{From: "bob", To: "alice", Points: 5, Kind: KindThing})
+	if err != nil {
+		t.Fatalf("db.InsertPoints: %v", err)
+	}
+
+	user, err := db.GetUser(ctx, "alice", nil)
+	if err != nil || user.Points != 2 {
+		t.Errorf("db.GetUser(ctx, %q, nil) = %+v, %v; want 2 points", "alice", user, err)
+	}
+
+	user, err = db.WithTeam("T1").GetUser(ctx, "alice", nil)
+	if err != nil || user.Points != 5 {
+		t.Errorf("db.WithTeam(%q).GetUser(ctx, %q, nil) = %+v, %v; want 5 points", "T1", "alice", user, err)
+	}
+
+	total, err := db.GetTotalPoints(ctx, nil)
+	if err != nil || total != 6 {
+		t.Errorf("db.GetTotalPoints(ctx, nil) = %d, %v; want 6", total, err)
+	}
+}
+
+func TestRebuildTotals(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, p := range []*Points{
+		{From: "bob", To: "alice", Points: 3},
+		{From: "alice", To: "bob", Points: -2},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	_, err := db.SQL.Exec("update user_totals set `points` = 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.SQL.Exec("insert into user_totals (`team`, `name`, `points`, `operations`, `abs_points`) values ('', 'carol', 1, 1, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.RebuildTotals(ctx)
+	if err != nil {
+		t.Fatalf("db.RebuildTotals: %v", err)
+	}
+
+	leaderboard, err := db.GetLeaderboard(ctx, 10, nil)
+	if err != nil {
+		t.Fatalf("db.GetLeaderboard: %v", err)
+	}
+	if len(leaderboard) != 2 || leaderboard[0].Name != "alice" || leaderboard[0].Points != 3 || leaderboard[1].Name != "bob" || leaderboard[1].Points != -2 {
+		t.Errorf("leaderboard is %+v; want alice with 3 points and bob with -2", leaderboard)
+	}
+}
+
+func TestGetRank(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, p := range []*Points{
+		{From: "dave", To: "alice", Points: 3, Channel: "C1"},
+		{From: "dave", To: "bob", Points: 3, Channel: "C2"},
+		{From: "dave", To: "carol", Points: 1, Channel: "C1"},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   int
+	}{
+		{"alice", nil, 1},
+		{"bob", nil, 1},
+		{"carol", nil, 3},
+		{"carol", &Filter{Channel: "C1"}, 2},
+		{"carol", &Filter{HalfLife: 24 * time.Hour}, 3},
+	}
+	for _, tt := range tests {
+		rank, err := db.GetRank(ctx, tt.name, tt.filter)
+		if err != nil {
+			t.Errorf("db.GetRank(ctx, %q, %+v): %v", tt.name, tt.filter, err)
+			continue
+		}
+		if rank != tt.want {
+			t.Errorf("db.GetRank(ctx, %q, %+v) = %d; want %d", tt.name, tt.filter, rank, tt.want)
+		}
+	}
+
+	_, err := db.GetRank(ctx, "erin", nil)
+	if err != ErrNoSuchUser {
+		t.Errorf("got error %v for an unknown user; want %v", err, ErrNoSuchUser)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	var (
+		ctx = context.Background()
+		now = time.Now()
+	)
+	insertAt(t, db, &Points{From: "bob", To: "U1", Points: 4, Kind: KindUser, Channel: "C1"}, now.Add(-48*time.Hour))
+	insertAt(t, db, &Points{From: "bob", To: "U1", Points: 1, Kind: KindUser, Channel: "C2"}, now)
+	insertAt(t, db, &Points{From: "bob", To: "coffee", Points: 2, Kind: KindThing, Channel: "C1"}, now)
+
+	err := db.SetUserName(ctx, "U1", "alice")
+	if err != nil {
+		t.Fatalf("db.SetUserName: %v", err)
+	}
+
+	users := []struct {
+		filter *Filter
+		name   string
+		points int
+	}{
+		{nil, "alice", 5},
+		{&Filter{Since: now.Add(-time.Hour)}, "alice", 1},
+		{&Filter{Until: now.Add(-time.Hour)}, "alice", 4},
+		{&Filter{Channel: "C1"}, "alice", 4},
+		{&Filter{Kind: KindUser}, "alice", 5},
+		{&Filter{HalfLife: 24 * time.Hour}, "alice", 2},
+	}
+	for _, tt := range users {
+		user, err := db.GetUser(ctx, "U1", tt.filter)
+		if err != nil {
+			t.Errorf("db.GetUser(ctx, %q, %+v): %v", "U1", tt.filter, err)
+			continue
+		}
+		if user.Name != tt.name || user.Points != tt.points {
+			t.Errorf("db.GetUser(ctx, %q, %+v) = %+v; want %s with %d points", "U1", tt.filter, user, tt.name, tt.points)
+		}
+	}
+
+	_, err = db.GetUser(ctx, "coffee", &Filter{Kind: KindUser})
+	if err != ErrNoSuchUser {
+		t.Errorf("got error %v for a thing filtered by users; want %v", err, ErrNoSuchUser)
+	}
+
+	leaderboards := []struct {
+		filter *Filter
+		want   []User
+	}{
+		{nil, []User{{"alice", 5}, {"coffee", 2}}},
+		{&Filter{Since: now.Add(-time.Hour)}, []User{{"coffee", 2}, {"alice", 1}}},
+		{&Filter{Kind: KindThing}, []User{{"coffee", 2}}},
+		{&Filter{Channel: "C2"}, []User{{"alice", 1}}},
+		{&Filter{HalfLife: 12 * time.Hour}, []User{{"coffee", 2}, {"alice", 1}}},
+	}
+	for _, tt := range leaderboards {
+		leaderboard, err := db.GetLeaderboard(ctx, 10, tt.filter)
+		if err != nil {
+			t.Errorf("db.GetLeaderboard(ctx, 10, %+v): %v", tt.filter, err)
+			continue
+		}
+
+		if len(leaderboard) != len(tt.want) {
+			t.Errorf("db.GetLeaderboard(ctx, 10, %+v) has %d users; want %d", tt.filter, len(leaderboard), len(tt.want))
+			continue
+		}
+		for i, user := range leaderboard {
+			if *user != tt.want[i] {
+				t.Errorf("db.GetLeaderboard(ctx, 10, %+v)[%d] = %+v; want %+v", tt.filter, i, user, tt.want[i])
+			}
+		}
+	}
+
+	decayed := &Filter{HalfLife: 12 * time.Hour}
+	leaderboard, err := db.GetLeaderboard(ctx, 1, decayed)
+	if err != nil || len(leaderboard) != 1 || *leaderboard[0] != (User{"coffee", 2}) {
+		t.Errorf("db.GetLeaderboard(ctx, 1, %+v) = %+v, %v; want only coffee with 2 points", decayed, leaderboard, err)
+	}
+}
+
+func TestRevokeLast(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	var (
+		ctx   = context.Background()
+		now   = time.Now()
+		since = now.Add(-5 * time.Minute)
+	)
+	insertAt(t, db, &Points{From: "bob", To: "alice", Points: 2, Channel: "C1"}, now.Add(-time.Hour))
+	err := db.InsertPoints(ctx, &Points{From: "bob", To: "alice", Points: 3, Reason: "the launch", Channel: "C1"})
+	if err != nil {
+		t.Fatalf("db.InsertPoints: %v", err)
+	}
+
+	record, err := db.RevokeLast(ctx, "bob", since)
+	if err != nil {
+		t.Fatalf("db.RevokeLast: %v", err)
+	}
+	if record.To != "alice" || record.Points != 3 || record.Reason != "the launch" {
+		t.Errorf("revoked %+v; want the latest operation", record)
+	}
+
+	for _, filter := range []*Filter{nil, {Channel: "C1"}} {
+		user, err := db.GetUser(ctx, "alice", filter)
+		if err != nil || user.Points != 2 {
+			t.Errorf("db.GetUser(ctx, %q, %+v) = %+v, %v; want 2 points", "alice", filter, user, err)
+		}
+
+		leaderboard, err := db.GetLeaderboard(ctx, 10, filter)
+		if err != nil || len(leaderboard) != 1 || leaderboard[0].Points != 2 {
+			t.Errorf("db.GetLeaderboard(ctx, 10, %+v) = %+v, %v; want alice with 2 points", filter, leaderboard, err)
+		}
+	}
+
+	given, err := db.GetPointsGiven(ctx, "bob", since)
+	if err != nil || given != 0 {
+		t.Errorf("db.GetPointsGiven(ctx, %q, since) = %d, %v; want 0", "bob", given, err)
+	}
+
+	total, err := db.GetTotalPoints(ctx, nil)
+	if err != nil || total != 2 {
+		t.Errorf("db.GetTotalPoints(ctx, nil) = %d, %v; want 2", total, err)
+	}
+
+	// the remaining operation is older than the undo window
+	_, err = db.RevokeLast(ctx, "bob", since)
+	if err != ErrNoSuchRecord {
+		t.Errorf("got error %v for an operation outside of the undo window; want %v", err, ErrNoSuchRecord)
+	}
+}
+
+func TestGetPointsGiven(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	var (
+		ctx = context.Background()
+		now = time.Now()
+	)
+	insertAt(t, db, &Points{From: "bob", To: "alice", Points: 5}, now.Add(-48*time.Hour))
+	for _, p := range []*Points{
+		{From: "bob", To: "alice", Points: 1},
+		{From: "bob", To: "alice", Points: -1},
+		{From: "bob", To: "carol", Points: -2},
+		{From: "bob", To: "dave", Points: 3},
+		{From: "erin", To: "dave", Points: 4},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	// taking back a point that was given refunds it
+	given, err := db.GetPointsGiven(ctx, "bob", now.Add(-time.Hour))
+	if err != nil || given != 5 {
+		t.Errorf("db.GetPointsGiven(ctx, %q, since) = %d, %v; want 5", "bob", given, err)
+	}
+
+	for to, want := range map[string]int{"alice": 0, "carol": -2, "dave": 3, "erin": 0} {
+		given, err := db.GetPointsGivenTo(ctx, "bob", to, now.Add(-time.Hour))
+		if err != nil || given != want {
+			t.Errorf("db.GetPointsGivenTo(ctx, %q, %q, since) = %d, %v; want %d", "bob", to, given, err, want)
+		}
+	}
+}
+
+func TestSharedNames(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	var (
+		ctx   = context.Background()
+		teamA = db.WithTeam("TA")
+		teamB = db.WithTeam("TB")
+	)
+
+	// users and channels that are shared between teams, e.g. on
+	// Enterprise Grid, have the same ID in every team
+	for _, tt := range []struct {
+		db          *DB
+		user, chann string
+	}{
+		{teamA, "alice", "general"},
+		{teamB, "alice.b", "general-b"},
+		{teamA, "alice", "general"},
+	} {
+		if err := tt.db.SetUserName(ctx, "U1", tt.user); err != nil {
+			t.Fatalf("db.SetUserName: %v", err)
+		}
+		if err := tt.db.SetChannelName(ctx, "C1", tt.chann); err != nil {
+			t.Fatalf("db.SetChannelName: %v", err)
+		}
+		if err := tt.db.InsertPoints(ctx, &Points{From: "U2", To: "U1", Points: 1, Kind: KindUser}); err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		db          *DB
+		user, chann string
+	}{
+		{teamA, "alice", "general"},
+		{teamB, "alice.b", "general-b"},
+	} {
+		id, err := tt.db.GetUserID(ctx, tt.user)
+		if err != nil || id != "U1" {
+			t.Errorf("db.GetUserID(%q) in team %s = %q, %v; want %q", tt.user, tt.db.team, id, err, "U1")
+		}
+
+		id, err = tt.db.GetChannelID(ctx, tt.chann)
+		if err != nil || id != "C1" {
+			t.Errorf("db.GetChannelID(%q) in team %s = %q, %v; want %q", tt.chann, tt.db.team, id, err, "C1")
+		}
+
+		leaderboard, err := tt.db.GetLeaderboard(ctx, 10, nil)
+		if err != nil {
+			t.Fatalf("db.GetLeaderboard: %v", err)
+		}
+		if len(leaderboard) != 1 || leaderboard[0].Name != tt.user {
+			t.Errorf("leaderboard of team %s is %+v; want only %s", tt.db.team, leaderboard, tt.user)
+		}
+	}
+}
+
+func TestTeams(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	var (
+		ctx   = context.Background()
+		teamA = db.WithTeam("TA")
+		teamB = db.WithTeam("TB")
+	)
+
+	// db holds the records from before karmabot served multiple
+	// workspaces, which do not belong to any team
+	for _, tt := range []struct {
+		db     *DB
+		points int
+	}{
+		{db, 3},
+		{teamA, 1},
+		{teamB, 5},
+	} {
+		err := tt.db.InsertPoints(ctx, &Points{From: "bob", To: "U1", Points: tt.points, Kind: KindUser})
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+	db.SetUserName(ctx, "U1", "alice")
+	db.SetChannelName(ctx, "C1", "general")
+	teamB.SetUserName(ctx, "U2", "carol")
+
+	check := func(when string, db *DB, want int) {
+		t.Helper()
+
+		user, err := db.GetUser(ctx, "U1", nil)
+		switch {
+		case want == 0 && err != ErrNoSuchUser:
+			t.Errorf("%s: db.GetUser in team %q = %+v, %v; want %v", when, db.team, user, err, ErrNoSuchUser)
+		case want != 0 && (err != nil || user.Points != want):
+			t.Errorf("%s: db.GetUser in team %q = %+v, %v; want %d points", when, db.team, user, err, want)
+		}
+
+		total, err := db.GetTotalPoints(ctx, nil)
+		if err != nil || total != want {
+			t.Errorf("%s: db.GetTotalPoints in team %q = %d, %v; want %d", when, db.team, total, err, want)
+		}
+
+		leaderboard, err := db.GetLeaderboard(ctx, 10, &Filter{Kind: KindUser})
+		if err != nil || (want == 0) != (len(leaderboard) == 0) {
+			t.Errorf("%s: db.GetLeaderboard in team %q = %+v, %v; want %d points", when, db.team, leaderboard, err, want)
+		}
+	}
+
+	check("before AssignTeam", db, 3)
+	check("before AssignTeam", teamA, 1)
+	check("before AssignTeam", teamB, 5)
+
+	for _, tt := range []struct {
+		db   *DB
+		name string
+		err  error
+	}{
+		{db, "alice", nil},
+		{teamA, "alice", ErrNoSuchUser},
+		{teamA, "carol", ErrNoSuchUser},
+		{teamB, "carol", nil},
+	} {
+		if _, err := tt.db.GetUserID(ctx, tt.name); err != tt.err {
+			t.Errorf("before AssignTeam: db.GetUserID(%q) in team %q returned %v; want %v", tt.name, tt.db.team, err, tt.err)
+		}
+	}
+
+	moved, err := db.AssignTeam(ctx, "TA")
+	if err != nil {
+		t.Fatalf("db.AssignTeam: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("db.AssignTeam moved %d records; want 1", moved)
+	}
+
+	check("after AssignTeam", db, 0)
+	check("after AssignTeam", teamA, 4)
+	check("after AssignTeam", teamB, 5)
+
+	if id, err := teamA.GetUserID(ctx, "alice"); err != nil || id != "U1" {
+		t.Errorf("after AssignTeam: db.GetUserID(%q) in team %q = %q, %v; want %q", "alice", "TA", id, err, "U1")
+	}
+	if id, err := teamA.GetChannelID(ctx, "general"); err != nil || id != "C1" {
+		t.Errorf("after AssignTeam: db.GetChannelID(%q) in team %q = %q, %v; want %q", "general", "TA", id, err, "C1")
+	}
+	if _, err := teamB.GetUserID(ctx, "alice"); err != ErrNoSuchUser {
+		t.Errorf("after AssignTeam: db.GetUserID(%q) in team %q returned %v; want %v", "alice", "TB", err, ErrNoSuchUser)
+	}
+}
+
+func TestGetGivers(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, p := range []*Points{
+		{From: "U1", To: "carol", Points: 2},
+		{From: "U1", To: "carol", Points: -5},
+		{From: "U1", To: "dave", Points: 1},
+		{From: "bob", To: "carol", Points: 3},
+		{From: "erin", To: "carol", Points: -1},
+		{From: SystemGiver, To: "carol", Points: 10},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	err := db.SetUserName(ctx, "U1", "alice")
+	if err != nil {
+		t.Fatalf("db.SetUserName: %v", err)
+	}
+
+	// downvotes and karmabotctl's operations are not counted, and
+	// ties are broken by the number of operations
+	givers, err := db.GetGivers(ctx, 10)
+	if err != nil {
+		t.Fatalf("db.GetGivers: %v", err)
+	}
+
+	want := []Giver{
+		{Name: "alice", Operations: 2, Points: 3},
+		{Name: "bob", Operations: 1, Points: 3},
+	}
+	if len(givers) != len(want) {
+		t.Fatalf("givers are %+v; want %+v", givers, want)
+	}
+	for i, giver := range givers {
+		if *giver != want[i] {
+			t.Errorf("givers[%d] = %+v; want %+v", i, giver, want[i])
+		}
+	}
+
+	givers, err = db.GetGivers(ctx, 1)
+	if err != nil || len(givers) != 1 {
+		t.Errorf("db.GetGivers(ctx, 1) = %+v, %v; want 1 giver", givers, err)
+	}
+}
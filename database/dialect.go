@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// A dialect describes the differences between the SQL
+// flavours that karmabot supports.
+type dialect struct {
+	// driver is the name of the database, which selects the
+	// migrations that apply to it.
+	driver string
+
+	// sqlDriver is the name of the database/sql driver that
+	// connects to the database.
+	sqlDriver string
+
+	// quote is the character used to quote identifiers.
+	quote string
+
+	// numberedParams is set for databases that use $1, $2, ...
+	// as query placeholders instead of ?.
+	numberedParams bool
+
+	// lockMigrations is executed at the beginning of every
+	// migration's transaction in order to keep multiple
+	// karmabot instances from migrating concurrently.
+	lockMigrations string
+
+	// decay is an expression for the weight of a karma record's
+	// points at the time passed as its first parameter, given a
+	// half-life in seconds as its second parameter. Records newer
+	// than the time are not weighted.
+	decay string
+}
+
+var (
+	sqlite3Dialect = &dialect{
+		driver:    "sqlite3",
+		sqlDriver: "sqlite3_karmabot",
+		quote:     "`",
+		decay:     "pow(0.5, max(0, (julianday(?) - julianday(karma.`timestamp`)) * 86400) / ?)",
+	}
+
+	postgresDialect = &dialect{
+		driver:         "postgres",
+		sqlDriver:      "postgres",
+		quote:          `"`,
+		numberedParams: true,
+		lockMigrations: "select pg_advisory_xact_lock(7072706)",
+		// the exponent is capped because PostgreSQL reports
+		// an underflow instead of returning 0
+		decay: "power(0.5, least(greatest(0, extract(epoch from cast(? as timestamp) - cast(karma.`timestamp` as timestamp))) / cast(? as double precision), 1000))",
+	}
+)
+
+// The sqlite3 driver is registered with a pow function, which
+// SQLite lacks, for decaying points.
+func init() {
+	sql.Register(sqlite3Dialect.sqlDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("pow", math.Pow, true)
+		},
+	})
+}
+
+// dialectFor returns the dialect matching the passed DSN.
+// postgres:// and postgresql:// URLs select PostgreSQL and
+// anything else is treated as a path to an sqlite3 database.
+func dialectFor(dsn string) *dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgresDialect
+	}
+
+	return sqlite3Dialect
+}
+
+// rebind rewrites a query that quotes identifiers with backticks
+// and uses ? as placeholders into the dialect's own syntax. String
+// literals are left as they are.
+func (d *dialect) rebind(query string) string {
+	if d.quote == "`" && !d.numberedParams {
+		return query
+	}
+
+	var (
+		b       strings.Builder
+		n       = 0
+		literal = false
+	)
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			// a quote escaped by doubling it ends and
+			// restarts the literal
+			literal = !literal
+		case literal:
+		case c == '`':
+			b.WriteString(d.quote)
+			continue
+		case c == '?' && d.numberedParams:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
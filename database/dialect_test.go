@@ -0,0 +1,58 @@
+package database
+
+import "testing"
+
+func TestDialectFor(t *testing.T) {
+	tests := map[string]*dialect{
+		"./db.sqlite3":                          sqlite3Dialect,
+		"/var/lib/karmabot/db.sqlite3":          sqlite3Dialect,
+		"file:db.sqlite3?cache=shared":          sqlite3Dialect,
+		"postgres://karmabot@localhost/karma":   postgresDialect,
+		"postgresql://karmabot@localhost/karma": postgresDialect,
+		"./postgres://db.sqlite3":               sqlite3Dialect,
+	}
+
+	for dsn, want := range tests {
+		if got := dialectFor(dsn); got != want {
+			t.Errorf("dialectFor(%q) = %s; want %s", dsn, got.driver, want.driver)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query            string
+		sqlite, postgres string
+	}{
+		{
+			query:    "select `to` from karma",
+			sqlite:   "select `to` from karma",
+			postgres: `select "to" from karma`,
+		},
+		{
+			query:    "insert into karma (`from`, `to`) values (?, ?)",
+			sqlite:   "insert into karma (`from`, `to`) values (?, ?)",
+			postgres: `insert into karma ("from", "to") values ($1, $2)`,
+		},
+		{
+			query:    "select ? from karma where `reason` = '?' and `to` = ?",
+			sqlite:   "select ? from karma where `reason` = '?' and `to` = ?",
+			postgres: `select $1 from karma where "reason" = '?' and "to" = $2`,
+		},
+		{
+			query:    "select '`it''s ?`', ? from karma",
+			sqlite:   "select '`it''s ?`', ? from karma",
+			postgres: "select '`it''s ?`', $1 from karma",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := sqlite3Dialect.rebind(tt.query); got != tt.sqlite {
+			t.Errorf("sqlite3Dialect.rebind(%q) = %q; want %q", tt.query, got, tt.sqlite)
+		}
+
+		if got := postgresDialect.rebind(tt.query); got != tt.postgres {
+			t.Errorf("postgresDialect.rebind(%q) = %q; want %q", tt.query, got, tt.postgres)
+		}
+	}
+}
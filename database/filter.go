@@ -0,0 +1,87 @@
+package database
+
+import (
+	"math"
+	"time"
+)
+
+// A Filter narrows down the karma records that a query
+// takes into account. Zero fields are ignored and a nil
+// Filter matches all records. A Filter can also weight the
+// records by their age, see HalfLife.
+type Filter struct {
+	// Since and Until limit the records to the ones
+	// created in the [Since, Until) time range.
+	Since, Until time.Time
+
+	// Kind limits the records to the ones whose
+	// recipient is of a specific kind.
+	Kind string
+
+	// Channel limits the records to the ones created
+	// in the Slack channel with this ID.
+	Channel string
+
+	// HalfLife, if set, makes points decay over time: a
+	// record's points lose half of their value every
+	// HalfLife. It applies to leaderboards and users only.
+	HalfLife time.Duration
+}
+
+// IsZero reports whether the filter matches all records
+// and weights them equally.
+func (f *Filter) IsZero() bool {
+	return f == nil || (f.Since.IsZero() && f.Until.IsZero() && f.Kind == "" && f.Channel == "" && f.HalfLife <= 0)
+}
+
+// Score returns the value of a record's points at now, given
+// that the record was created at timestamp. Without a HalfLife
+// it returns the points as they are.
+func (f *Filter) Score(points int, timestamp, now time.Time) float64 {
+	if f == nil || f.HalfLife <= 0 {
+		return float64(points)
+	}
+
+	age := now.Sub(timestamp)
+	if age < 0 {
+		age = 0
+	}
+
+	return float64(points) * math.Exp2(-float64(age)/float64(f.HalfLife))
+}
+
+// where returns the conditions that the filter adds to
+// a query on the karma table, along with their arguments.
+// Every condition is prefixed with "and".
+func (f *Filter) where() (string, []interface{}) {
+	var (
+		conds string
+		args  []interface{}
+	)
+
+	if f == nil {
+		return conds, args
+	}
+
+	if !f.Since.IsZero() {
+		conds += " and karma.`timestamp` >= ?"
+		args = append(args, f.Since.UTC().Format(timeFormat))
+	}
+
+	if !f.Until.IsZero() {
+		conds += " and karma.`timestamp` < ?"
+		args = append(args, f.Until.UTC().Format(timeFormat))
+	}
+
+	if f.Kind != "" {
+		conds += " and karma.`kind` = ?"
+		args = append(args, f.Kind)
+	}
+
+	if f.Channel != "" {
+		conds += " and karma.`channel` = ?"
+		args = append(args, f.Channel)
+	}
+
+	return conds, args
+}
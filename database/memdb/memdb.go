@@ -0,0 +1,595 @@
+// Package memdb implements a karmabot database that keeps all
+// karma records in memory. It is meant for demos and ephemeral
+// development bots, and can optionally be loaded from and saved
+// to a JSON snapshot file.
+package memdb
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamaln7/karmabot/database"
+)
+
+// Scheme is the prefix of DSNs that select an in-memory
+// database. Anything after it is the snapshot path.
+const Scheme = "memory://"
+
+// Config contains the config options of an in-memory
+// database. If Path is set, the database is loaded from
+// it on startup and saved to it by Save. Now, if set,
+// replaces time.Now as the database's clock.
+type Config struct {
+	Path string
+	Now  func() time.Time
+}
+
+// A DB is an instance of an in-memory karmabot database.
+type DB struct {
+	Config *Config
+
+	store *store
+	team  string
+}
+
+// A store holds the records of all teams. It is shared
+// between the DBs returned by WithTeam. Users and Channels
+// map team IDs to the cached names of the team's Slack users
+// and channels by their IDs.
+type store struct {
+	sync.RWMutex
+
+	Records  []*record                    `json:"records"`
+	Users    map[string]map[string]string `json:"users"`
+	Channels map[string]map[string]string `json:"channels"`
+}
+
+// A record is a single karma operation.
+type record struct {
+	Team      string     `json:"team"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Reason    string     `json:"reason"`
+	Points    int        `json:"points"`
+	Kind      string     `json:"kind"`
+	Channel   string     `json:"channel,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// New returns a new in-memory database, loaded from
+// the snapshot file if there is one.
+func New(config *Config) (*DB, error) {
+	db := &DB{
+		Config: config,
+		store: &store{
+			Users:    make(map[string]map[string]string),
+			Channels: make(map[string]map[string]string),
+		},
+	}
+
+	if config.Path == "" {
+		return db, nil
+	}
+
+	f, err := os.Open(config.Path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(db.store)
+	if err != nil {
+		return nil, err
+	}
+
+	if db.store.Users == nil {
+		db.store.Users = make(map[string]map[string]string)
+	}
+
+	if db.store.Channels == nil {
+		db.store.Channels = make(map[string]map[string]string)
+	}
+
+	return db, nil
+}
+
+// Save writes the database to its snapshot file. It is
+// a no-op if no snapshot path has been configured.
+func (db *DB) Save() error {
+	if db.Config.Path == "" {
+		return nil
+	}
+
+	db.store.RLock()
+	data, err := json.Marshal(db.store)
+	db.store.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that a failed
+	// write does not leave a truncated snapshot behind
+	tmp, err := ioutil.TempFile(filepath.Dir(db.Config.Path), filepath.Base(db.Config.Path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), db.Config.Path)
+}
+
+// WithTeam returns a copy of the DB that shares its records
+// but only reads and writes the ones that belong to the passed
+// Slack team.
+func (db *DB) WithTeam(team string) *DB {
+	scoped := *db
+	scoped.team = team
+
+	return &scoped
+}
+
+// now returns the current time according to the database's clock.
+func (db *DB) now() time.Time {
+	if db.Config.Now != nil {
+		return db.Config.Now()
+	}
+
+	return time.Now()
+}
+
+// active reports whether r belongs to the DB's team, has not
+// been revoked and matches the filter.
+func (db *DB) active(r *record, filter *database.Filter) bool {
+	if r.Team != db.team || r.RevokedAt != nil {
+		return false
+	}
+
+	if filter == nil {
+		return true
+	}
+
+	if !filter.Since.IsZero() && r.Timestamp.Before(filter.Since) {
+		return false
+	}
+
+	if !filter.Until.IsZero() && !r.Timestamp.Before(filter.Until) {
+		return false
+	}
+
+	if filter.Kind != "" && r.Kind != filter.Kind {
+		return false
+	}
+
+	if filter.Channel != "" && r.Channel != filter.Channel {
+		return false
+	}
+
+	return true
+}
+
+// displayName returns the cached username of a Slack user ID, or
+// the passed string itself if it does not belong to a known user.
+// The caller must hold the store's lock.
+func (db *DB) displayName(id string) string {
+	if name, ok := db.store.Users[db.team][id]; ok {
+		return name
+	}
+
+	return id
+}
+
+// throwback returns r with known Slack users referred to by
+// their cached usernames. The caller must hold the store's lock.
+func (db *DB) throwback(r *record) *database.Throwback {
+	return &database.Throwback{
+		Points: database.Points{
+			From:    db.displayName(r.From),
+			To:      db.displayName(r.To),
+			Reason:  r.Reason,
+			Points:  r.Points,
+			Kind:    r.Kind,
+			Channel: r.Channel,
+		},
+		Timestamp: r.Timestamp,
+	}
+}
+
+// InsertPoints records a karma operation.
+func (db *DB) InsertPoints(ctx context.Context, points *database.Points) error {
+	db.store.Lock()
+	defer db.store.Unlock()
+
+	db.store.Records = append(db.store.Records, &record{
+		Team:      db.team,
+		From:      points.From,
+		To:        points.To,
+		Reason:    points.Reason,
+		Points:    points.Points,
+		Kind:      points.Kind,
+		Channel:   points.Channel,
+		Timestamp: db.now().UTC(),
+	})
+
+	return nil
+}
+
+// SetUserName caches the username of the Slack user with the
+// passed ID. Cached usernames are shown instead of IDs.
+func (db *DB) SetUserName(ctx context.Context, id, name string) error {
+	db.store.Lock()
+	defer db.store.Unlock()
+
+	setName(db.store.Users, db.team, id, name)
+
+	return nil
+}
+
+// GetUserID returns the ID of the Slack user with the passed
+// username, provided that it has been cached before.
+func (db *DB) GetUserID(ctx context.Context, name string) (string, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	for id, n := range db.store.Users[db.team] {
+		if strings.EqualFold(n, name) {
+			return id, nil
+		}
+	}
+
+	return "", database.ErrNoSuchUser
+}
+
+// SetChannelName caches the name of the Slack channel with the
+// passed ID so that channels can be looked up by name.
+func (db *DB) SetChannelName(ctx context.Context, id, name string) error {
+	db.store.Lock()
+	defer db.store.Unlock()
+
+	setName(db.store.Channels, db.team, id, name)
+
+	return nil
+}
+
+// GetChannelID returns the ID of the Slack channel with the passed
+// name, provided that it has been cached before.
+func (db *DB) GetChannelID(ctx context.Context, name string) (string, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	for id, n := range db.store.Channels[db.team] {
+		if strings.EqualFold(n, name) {
+			return id, nil
+		}
+	}
+
+	return "", database.ErrNoSuchChannel
+}
+
+// setName caches the name of a team's Slack user or channel in
+// names. The caller must hold the store's lock.
+func setName(names map[string]map[string]string, team, id, name string) {
+	if names[team] == nil {
+		names[team] = make(map[string]string)
+	}
+
+	names[team][id] = name
+}
+
+// GetUser returns info about a user. Its name is the cached
+// username if the user is a known Slack user. Users' points are
+// computed from the karma records that match the filter.
+func (db *DB) GetUser(ctx context.Context, name string, filter *database.Filter) (*database.User, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	var (
+		now    = db.now()
+		points float64
+		found  bool
+	)
+	for _, r := range db.store.Records {
+		if r.To == name && db.active(r, filter) {
+			points += filter.Score(r.Points, r.Timestamp, now)
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, database.ErrNoSuchUser
+	}
+
+	return &database.User{
+		Name:   db.displayName(name),
+		Points: int(math.Round(points)),
+	}, nil
+}
+
+// GetLeaderboard returns the leaderboard with the top X users,
+// taking only the records that match the filter into account.
+func (db *DB) GetLeaderboard(ctx context.Context, limit int, filter *database.Filter) (database.Leaderboard, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	var (
+		now    = db.now()
+		order  []string
+		scores = make(map[string]float64)
+	)
+	for _, r := range db.store.Records {
+		if !db.active(r, filter) {
+			continue
+		}
+
+		if _, ok := scores[r.To]; !ok {
+			order = append(order, r.To)
+		}
+
+		scores[r.To] += filter.Score(r.Points, r.Timestamp, now)
+	}
+
+	leaderboard := make(database.Leaderboard, 0, len(order))
+	for _, to := range order {
+		leaderboard = append(leaderboard, &database.User{
+			Name:   db.displayName(to),
+			Points: int(math.Round(scores[to])),
+		})
+	}
+
+	sort.SliceStable(leaderboard, func(i, j int) bool {
+		return leaderboard[i].Points > leaderboard[j].Points
+	})
+
+	if limit >= 0 && len(leaderboard) > limit {
+		leaderboard = leaderboard[:limit]
+	}
+
+	return leaderboard, nil
+}
+
+// GetRank returns the position of a user on the leaderboard for the
+// records that match the filter. Users with the same points share a
+// rank.
+func (db *DB) GetRank(ctx context.Context, name string, filter *database.Filter) (int, error) {
+	user, err := db.GetUser(ctx, name, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	leaderboard, err := db.GetLeaderboard(ctx, -1, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	rank := 1
+	for _, u := range leaderboard {
+		if u.Points > user.Points {
+			rank++
+		}
+	}
+
+	return rank, nil
+}
+
+// GetGivers returns the top X users who have given the most points.
+// Only karma operations that gave positive points are counted, and
+// operations made with karmabotctl are left out.
+func (db *DB) GetGivers(ctx context.Context, limit int) (database.Givers, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	var (
+		givers database.Givers
+		users  = make(map[string]*database.Giver)
+	)
+	for _, r := range db.store.Records {
+		if r.Points <= 0 || r.From == database.SystemGiver || !db.active(r, nil) {
+			continue
+		}
+
+		g, ok := users[r.From]
+		if !ok {
+			g = &database.Giver{Name: db.displayName(r.From)}
+			users[r.From] = g
+			givers = append(givers, g)
+		}
+
+		g.Operations++
+		g.Points += r.Points
+	}
+
+	sort.SliceStable(givers, func(i, j int) bool {
+		if givers[i].Points != givers[j].Points {
+			return givers[i].Points > givers[j].Points
+		}
+
+		return givers[i].Operations > givers[j].Operations
+	})
+
+	if limit >= 0 && len(givers) > limit {
+		givers = givers[:limit]
+	}
+
+	return givers, nil
+}
+
+// GetTotalPoints returns the amount of points given or taken
+// for all users in the records that match the filter.
+func (db *DB) GetTotalPoints(ctx context.Context, filter *database.Filter) (int, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	var total int
+	for _, r := range db.store.Records {
+		if !db.active(r, filter) {
+			continue
+		}
+
+		if r.Points < 0 {
+			total -= r.Points
+		} else {
+			total += r.Points
+		}
+	}
+
+	return total, nil
+}
+
+// GetPointsGiven returns the number of points that a user has
+// given or taken at or after since. Points given to and taken from
+// the same recipient cancel each other out.
+func (db *DB) GetPointsGiven(ctx context.Context, from string, since time.Time) (int, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	given := make(map[string]int)
+	for _, r := range db.store.Records {
+		if r.From != from || r.Timestamp.Before(since) || !db.active(r, nil) {
+			continue
+		}
+
+		given[r.To] += r.Points
+	}
+
+	var points int
+	for _, p := range given {
+		if p < 0 {
+			points -= p
+		} else {
+			points += p
+		}
+	}
+
+	return points, nil
+}
+
+// GetPointsGivenTo returns the net number of points that a user
+// has given to a recipient at or after since.
+func (db *DB) GetPointsGivenTo(ctx context.Context, from, to string, since time.Time) (int, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	var points int
+	for _, r := range db.store.Records {
+		if r.From == from && r.To == to && !r.Timestamp.Before(since) && db.active(r, nil) {
+			points += r.Points
+		}
+	}
+
+	return points, nil
+}
+
+// GetThrowback returns a random karma operation on a specific user.
+func (db *DB) GetThrowback(ctx context.Context, name string) (*database.Throwback, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	var records []*record
+	for _, r := range db.store.Records {
+		if r.To == name && db.active(r, nil) {
+			records = append(records, r)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, database.ErrNoSuchUser
+	}
+
+	return db.throwback(records[rand.Intn(len(records))]), nil
+}
+
+// GetHistory returns the latest karma operations on a specific
+// user, newest first.
+func (db *DB) GetHistory(ctx context.Context, name string, limit, offset int) ([]*database.Throwback, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	var history []*database.Throwback
+	for i := len(db.store.Records) - 1; i >= 0 && len(history) < limit; i-- {
+		r := db.store.Records[i]
+		if r.To != name || !db.active(r, nil) {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		history = append(history, db.throwback(r))
+	}
+
+	return history, nil
+}
+
+// GetReasons returns the X most frequent reasons for which a user
+// has received karma. Reasons are grouped by their normalized form.
+func (db *DB) GetReasons(ctx context.Context, name string, limit int) (database.Reasons, error) {
+	db.store.RLock()
+	defer db.store.RUnlock()
+
+	counter := make(database.ReasonCounter)
+	for _, r := range db.store.Records {
+		if r.To == name && db.active(r, nil) {
+			counter.Add(r.Reason, 1, r.Points)
+		}
+	}
+
+	return counter.Top(limit), nil
+}
+
+// RevokeLast revokes the latest karma operation performed by a user
+// at or after since. Revoked records are kept but ignored by all
+// lookups. It returns the revoked record with the recipient's ID.
+func (db *DB) RevokeLast(ctx context.Context, from string, since time.Time) (*database.Points, error) {
+	db.store.Lock()
+	defer db.store.Unlock()
+
+	for i := len(db.store.Records) - 1; i >= 0; i-- {
+		r := db.store.Records[i]
+		if r.From != from || !db.active(r, nil) {
+			continue
+		}
+
+		if r.Timestamp.Before(since) {
+			break
+		}
+
+		now := db.now().UTC()
+		r.RevokedAt = &now
+
+		return &database.Points{
+			From:    r.From,
+			To:      r.To,
+			Reason:  r.Reason,
+			Points:  r.Points,
+			Kind:    r.Kind,
+			Channel: r.Channel,
+		}, nil
+	}
+
+	return nil, database.ErrNoSuchRecord
+}
@@ -0,0 +1,452 @@
+package memdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kamaln7/karmabot/database"
+)
+
+// newTestDB returns an empty in-memory database.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return db
+}
+
+// insertAt inserts a karma record with a specific timestamp, which
+// InsertPoints always sets to now.
+func insertAt(t *testing.T, db *DB, points *database.Points, timestamp time.Time) {
+	t.Helper()
+
+	err := db.InsertPoints(context.Background(), points)
+	if err != nil {
+		t.Fatalf("db.InsertPoints: %v", err)
+	}
+
+	db.store.Records[len(db.store.Records)-1].Timestamp = timestamp.UTC()
+}
+
+func TestInsertPoints(t *testing.T) {
+	db := newTestDB(t)
+
+	ctx := context.Background()
+	for _, p := range []*database.Points{
+		{From: "bob", To: "alice", Points: 3},
+		{From: "bob", To: "alice", Points: -1},
+		{From: "alice", To: "bob", Points: 2},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	// records in other teams are kept apart
+	err := db.WithTeam("T1").InsertPoints(ctx, &database.Points{From: "bob", To: "alice", Points: 5})
+	if err != nil {
+		t.Fatalf("db.InsertPoints: %v", err)
+	}
+
+	user, err := db.GetUser(ctx, "alice", nil)
+	if err != nil || user.Points != 2 {
+		t.Errorf("db.GetUser(ctx, %q, nil) = %+v, %v; want 2 points", "alice", user, err)
+	}
+
+	user, err = db.WithTeam("T1").GetUser(ctx, "alice", nil)
+	if err != nil || user.Points != 5 {
+		t.Errorf("db.WithTeam(%q).GetUser(ctx, %q, nil) = %+v, %v; want 5 points", "T1", "alice", user, err)
+	}
+
+	_, err = db.WithTeam("T2").GetUser(ctx, "alice", nil)
+	if err != database.ErrNoSuchUser {
+		t.Errorf("got error %v for a user in another team; want %v", err, database.ErrNoSuchUser)
+	}
+
+	total, err := db.GetTotalPoints(ctx, nil)
+	if err != nil || total != 6 {
+		t.Errorf("db.GetTotalPoints(ctx, nil) = %d, %v; want 6", total, err)
+	}
+}
+
+func TestGetRank(t *testing.T) {
+	db := newTestDB(t)
+
+	ctx := context.Background()
+	for _, p := range []*database.Points{
+		{From: "dave", To: "alice", Points: 3, Channel: "C1"},
+		{From: "dave", To: "bob", Points: 3, Channel: "C2"},
+		{From: "dave", To: "carol", Points: 1, Channel: "C1"},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter *database.Filter
+		want   int
+	}{
+		{"alice", nil, 1},
+		{"bob", nil, 1},
+		{"carol", nil, 3},
+		{"carol", &database.Filter{Channel: "C1"}, 2},
+		{"carol", &database.Filter{HalfLife: 24 * time.Hour}, 3},
+	}
+	for _, tt := range tests {
+		rank, err := db.GetRank(ctx, tt.name, tt.filter)
+		if err != nil {
+			t.Errorf("db.GetRank(ctx, %q, %+v): %v", tt.name, tt.filter, err)
+			continue
+		}
+		if rank != tt.want {
+			t.Errorf("db.GetRank(ctx, %q, %+v) = %d; want %d", tt.name, tt.filter, rank, tt.want)
+		}
+	}
+
+	_, err := db.GetRank(ctx, "erin", nil)
+	if err != database.ErrNoSuchUser {
+		t.Errorf("got error %v for an unknown user; want %v", err, database.ErrNoSuchUser)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	db := newTestDB(t)
+
+	var (
+		ctx = context.Background()
+		now = time.Now()
+	)
+	insertAt(t, db, &database.Points{From: "bob", To: "U1", Points: 4, Kind: database.KindUser, Channel: "C1"}, now.Add(-48*time.Hour))
+	insertAt(t, db, &database.Points{From: "bob", To: "U1", Points: 1, Kind: database.KindUser, Channel: "C2"}, now)
+	insertAt(t, db, &database.Points{From: "bob", To: "coffee", Points: 2, Kind: database.KindThing, Channel: "C1"}, now)
+
+	err := db.SetUserName(ctx, "U1", "alice")
+	if err != nil {
+		t.Fatalf("db.SetUserName: %v", err)
+	}
+
+	users := []struct {
+		filter *database.Filter
+		name   string
+		points int
+	}{
+		{nil, "alice", 5},
+		{&database.Filter{Since: now.Add(-time.Hour)}, "alice", 1},
+		{&database.Filter{Until: now.Add(-time.Hour)}, "alice", 4},
+		{&database.Filter{Channel: "C1"}, "alice", 4},
+		{&database.Filter{Kind: database.KindUser}, "alice", 5},
+		{&database.Filter{HalfLife: 24 * time.Hour}, "alice", 2},
+	}
+	for _, tt := range users {
+		user, err := db.GetUser(ctx, "U1", tt.filter)
+		if err != nil {
+			t.Errorf("db.GetUser(ctx, %q, %+v): %v", "U1", tt.filter, err)
+			continue
+		}
+		if user.Name != tt.name || user.Points != tt.points {
+			t.Errorf("db.GetUser(ctx, %q, %+v) = %+v; want %s with %d points", "U1", tt.filter, user, tt.name, tt.points)
+		}
+	}
+
+	_, err = db.GetUser(ctx, "coffee", &database.Filter{Kind: database.KindUser})
+	if err != database.ErrNoSuchUser {
+		t.Errorf("got error %v for a thing filtered by users; want %v", err, database.ErrNoSuchUser)
+	}
+
+	leaderboards := []struct {
+		filter *database.Filter
+		want   []database.User
+	}{
+		{nil, []database.User{{Name: "alice", Points: 5}, {Name: "coffee", Points: 2}}},
+		{&database.Filter{Since: now.Add(-time.Hour)}, []database.User{{Name: "coffee", Points: 2}, {Name: "alice", Points: 1}}},
+		{&database.Filter{Kind: database.KindThing}, []database.User{{Name: "coffee", Points: 2}}},
+		{&database.Filter{Channel: "C2"}, []database.User{{Name: "alice", Points: 1}}},
+		{&database.Filter{HalfLife: 12 * time.Hour}, []database.User{{Name: "coffee", Points: 2}, {Name: "alice", Points: 1}}},
+	}
+	for _, tt := range leaderboards {
+		leaderboard, err := db.GetLeaderboard(ctx, 10, tt.filter)
+		if err != nil {
+			t.Errorf("db.GetLeaderboard(ctx, 10, %+v): %v", tt.filter, err)
+			continue
+		}
+
+		if len(leaderboard) != len(tt.want) {
+			t.Errorf("db.GetLeaderboard(ctx, 10, %+v) has %d users; want %d", tt.filter, len(leaderboard), len(tt.want))
+			continue
+		}
+		for i, user := range leaderboard {
+			if *user != tt.want[i] {
+				t.Errorf("db.GetLeaderboard(ctx, 10, %+v)[%d] = %+v; want %+v", tt.filter, i, user, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestGetGivers(t *testing.T) {
+	db := newTestDB(t)
+
+	ctx := context.Background()
+	for _, p := range []*database.Points{
+		{From: "U1", To: "carol", Points: 2},
+		{From: "U1", To: "carol", Points: -5},
+		{From: "U1", To: "dave", Points: 1},
+		{From: "bob", To: "carol", Points: 3},
+		{From: "erin", To: "carol", Points: -1},
+		{From: database.SystemGiver, To: "carol", Points: 10},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	err := db.SetUserName(ctx, "U1", "alice")
+	if err != nil {
+		t.Fatalf("db.SetUserName: %v", err)
+	}
+
+	// downvotes and karmabotctl's operations are not counted, and
+	// ties are broken by the number of operations
+	givers, err := db.GetGivers(ctx, 10)
+	if err != nil {
+		t.Fatalf("db.GetGivers: %v", err)
+	}
+
+	want := []database.Giver{
+		{Name: "alice", Operations: 2, Points: 3},
+		{Name: "bob", Operations: 1, Points: 3},
+	}
+	if len(givers) != len(want) {
+		t.Fatalf("givers are %+v; want %+v", givers, want)
+	}
+	for i, giver := range givers {
+		if *giver != want[i] {
+			t.Errorf("givers[%d] = %+v; want %+v", i, giver, want[i])
+		}
+	}
+
+	givers, err = db.GetGivers(ctx, 1)
+	if err != nil || len(givers) != 1 {
+		t.Errorf("db.GetGivers(ctx, 1) = %+v, %v; want 1 giver", givers, err)
+	}
+}
+
+func TestRevokeLast(t *testing.T) {
+	db := newTestDB(t)
+
+	var (
+		ctx   = context.Background()
+		now   = time.Now()
+		since = now.Add(-5 * time.Minute)
+	)
+	insertAt(t, db, &database.Points{From: "bob", To: "alice", Points: 2, Channel: "C1"}, now.Add(-time.Hour))
+	insertAt(t, db, &database.Points{From: "bob", To: "alice", Points: 3, Reason: "the launch", Channel: "C1"}, now)
+
+	record, err := db.RevokeLast(ctx, "bob", since)
+	if err != nil {
+		t.Fatalf("db.RevokeLast: %v", err)
+	}
+	if record.To != "alice" || record.Points != 3 || record.Reason != "the launch" {
+		t.Errorf("revoked %+v; want the latest operation", record)
+	}
+
+	for _, filter := range []*database.Filter{nil, {Channel: "C1"}} {
+		user, err := db.GetUser(ctx, "alice", filter)
+		if err != nil || user.Points != 2 {
+			t.Errorf("db.GetUser(ctx, %q, %+v) = %+v, %v; want 2 points", "alice", filter, user, err)
+		}
+
+		leaderboard, err := db.GetLeaderboard(ctx, 10, filter)
+		if err != nil || len(leaderboard) != 1 || leaderboard[0].Points != 2 {
+			t.Errorf("db.GetLeaderboard(ctx, 10, %+v) = %+v, %v; want alice with 2 points", filter, leaderboard, err)
+		}
+	}
+
+	given, err := db.GetPointsGiven(ctx, "bob", since)
+	if err != nil || given != 0 {
+		t.Errorf("db.GetPointsGiven(ctx, %q, since) = %d, %v; want 0", "bob", given, err)
+	}
+
+	// the remaining operation is older than the undo window
+	_, err = db.RevokeLast(ctx, "bob", since)
+	if err != database.ErrNoSuchRecord {
+		t.Errorf("got error %v for an operation outside of the undo window; want %v", err, database.ErrNoSuchRecord)
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	db := newTestDB(t)
+
+	ctx := context.Background()
+	for _, p := range []*database.Points{
+		{From: "U1", To: "bob", Points: 1, Reason: "first"},
+		{From: "U1", To: "carol", Points: 1},
+		{From: "U1", To: "bob", Points: -2, Reason: "second"},
+		{From: "U1", To: "bob", Points: 3, Reason: "third"},
+	} {
+		err := db.InsertPoints(ctx, p)
+		if err != nil {
+			t.Fatalf("db.InsertPoints: %v", err)
+		}
+	}
+
+	err := db.SetUserName(ctx, "U1", "alice")
+	if err != nil {
+		t.Fatalf("db.SetUserName: %v", err)
+	}
+
+	history, err := db.GetHistory(ctx, "bob", 2, 1)
+	if err != nil {
+		t.Fatalf("db.GetHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Reason != "second" || history[1].Reason != "first" {
+		t.Fatalf("history is %+v; want the second and first operations", history)
+	}
+	if history[0].From != "alice" {
+		t.Errorf("history lists giver %q; want %q", history[0].From, "alice")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "karmabot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ctx  = context.Background()
+		path = filepath.Join(dir, "karma.json")
+	)
+
+	// databases without a snapshot file start out empty
+	db, err := New(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	db.SetUserName(ctx, "U1234", "alice")
+	db.SetChannelName(ctx, "C1", "general")
+	db.InsertPoints(ctx, &database.Points{From: "bob", To: "U1234", Points: 5, Reason: "for the snapshot"})
+	db.InsertPoints(ctx, &database.Points{From: "bob", To: "U1234", Points: 2})
+	_, err = db.RevokeLast(ctx, "bob", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("db.RevokeLast: %v", err)
+	}
+
+	err = db.Save()
+	if err != nil {
+		t.Fatalf("db.Save: %v", err)
+	}
+
+	db, err = New(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	u, err := db.GetUser(ctx, "U1234", nil)
+	if err != nil {
+		t.Fatalf("db.GetUser: %v", err)
+	}
+	if u.Name != "alice" || u.Points != 5 {
+		t.Errorf("user is %+v; want alice with 5 points", u)
+	}
+
+	channel, err := db.GetChannelID(ctx, "General")
+	if err != nil || channel != "C1" {
+		t.Errorf("db.GetChannelID(ctx, %q) = %q, %v; want %q", "General", channel, err, "C1")
+	}
+
+	err = ioutil.WriteFile(path, []byte("{"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(&Config{Path: path})
+	if err == nil {
+		t.Errorf("New succeeded with a corrupt snapshot; want an error")
+	}
+}
+
+func TestGetPointsGiven(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = newTestDB(t)
+		now = time.Now()
+	)
+	insertAt(t, db, &database.Points{From: "bob", To: "alice", Points: 5}, now.Add(-48*time.Hour))
+	for _, p := range []*database.Points{
+		{From: "bob", To: "alice", Points: 1},
+		{From: "bob", To: "alice", Points: -1},
+		{From: "bob", To: "carol", Points: -2},
+		{From: "bob", To: "dave", Points: 3},
+		{From: "erin", To: "dave", Points: 4},
+	} {
+		insertAt(t, db, p, now)
+	}
+
+	// taking back a point that was given refunds it
+	given, err := db.GetPointsGiven(ctx, "bob", now.Add(-time.Hour))
+	if err != nil || given != 5 {
+		t.Errorf("db.GetPointsGiven(ctx, %q, since) = %d, %v; want 5", "bob", given, err)
+	}
+
+	for to, want := range map[string]int{"alice": 0, "carol": -2, "dave": 3, "erin": 0} {
+		given, err := db.GetPointsGivenTo(ctx, "bob", to, now.Add(-time.Hour))
+		if err != nil || given != want {
+			t.Errorf("db.GetPointsGivenTo(ctx, %q, %q, since) = %d, %v; want %d", "bob", to, given, err, want)
+		}
+	}
+}
+
+func TestSharedNames(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		db    = newTestDB(t)
+		teamA = db.WithTeam("TA")
+		teamB = db.WithTeam("TB")
+	)
+
+	// users and channels that are shared between teams, e.g. on
+	// Enterprise Grid, have the same ID in every team
+	teamA.SetUserName(ctx, "U1", "alice")
+	teamA.SetChannelName(ctx, "C1", "general")
+	teamB.SetUserName(ctx, "U1", "alice.b")
+	teamB.SetChannelName(ctx, "C1", "general-b")
+	teamA.InsertPoints(ctx, &database.Points{From: "U2", To: "U1", Points: 1})
+	teamB.InsertPoints(ctx, &database.Points{From: "U2", To: "U1", Points: 1})
+
+	for _, tt := range []struct {
+		db          *DB
+		user, chann string
+	}{
+		{teamA, "alice", "general"},
+		{teamB, "alice.b", "general-b"},
+	} {
+		id, err := tt.db.GetUserID(ctx, tt.user)
+		if err != nil || id != "U1" {
+			t.Errorf("db.GetUserID(%q) in team %s = %q, %v; want %q", tt.user, tt.db.team, id, err, "U1")
+		}
+
+		id, err = tt.db.GetChannelID(ctx, tt.chann)
+		if err != nil || id != "C1" {
+			t.Errorf("db.GetChannelID(%q) in team %s = %q, %v; want %q", tt.chann, tt.db.team, id, err, "C1")
+		}
+
+		u, err := tt.db.GetUser(ctx, "U1", nil)
+		if err != nil || u.Name != tt.user {
+			t.Errorf("db.GetUser in team %s = %+v, %v; want %s", tt.db.team, u, err, tt.user)
+		}
+	}
+}
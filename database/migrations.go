@@ -0,0 +1,333 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// A migration is a versioned change to the database schema.
+// Statements quote identifiers with ^ which is replaced with
+// the dialect's quote character before running them.
+type migration struct {
+	version int
+	name    string
+	up      map[string][]string
+}
+
+// MigrationStatus describes a migration and whether it
+// has been applied to the database.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// migrations lists all schema migrations in the order
+// in which they are applied. Append new migrations to
+// the end and never modify ones that have been released.
+var migrations = []*migration{
+	{
+		version: 1,
+		name:    "create karma table",
+		up: map[string][]string{
+			"sqlite3": {
+				`create table if not exists karma (
+					^id^ integer primary key,
+					^from^ text not null,
+					^to^ text not null,
+					^points^ integer not null,
+					^reason^ text,
+					^timestamp^ text not null default (datetime('now'))
+				)`,
+				"create index if not exists idx_to on karma(^to^)",
+			},
+			"postgres": {
+				`create table if not exists karma (
+					^id^ serial primary key,
+					^from^ text not null,
+					^to^ text not null,
+					^points^ integer not null,
+					^reason^ text,
+					^timestamp^ text not null default (to_char(now() at time zone 'utc', 'YYYY-MM-DD HH24:MI:SS'))
+				)`,
+				"create index if not exists idx_to on karma(^to^)",
+			},
+		},
+	},
+	{
+		version: 2,
+		name:    "create users table",
+		up: map[string][]string{
+			"sqlite3": {
+				"create table users (^id^ text primary key, ^name^ text not null)",
+				"create index idx_users_name on users(lower(^name^))",
+			},
+			"postgres": {
+				"create table users (^id^ text primary key, ^name^ text not null)",
+				"create index idx_users_name on users(lower(^name^))",
+			},
+		},
+	},
+	{
+		version: 3,
+		name:    "scope karma and users to slack teams",
+		up: map[string][]string{
+			"sqlite3": {
+				"alter table karma add column ^team^ text not null default ''",
+				"create index idx_team_to on karma(^team^, ^to^)",
+				"alter table users add column ^team^ text not null default ''",
+			},
+			"postgres": {
+				"alter table karma add column ^team^ text not null default ''",
+				"create index idx_team_to on karma(^team^, ^to^)",
+				"alter table users add column ^team^ text not null default ''",
+			},
+		},
+	},
+	{
+		version: 4,
+		name:    "create user_totals table",
+		up: map[string][]string{
+			"sqlite3": {
+				`create table user_totals (
+					^team^ text not null,
+					^name^ text not null,
+					^points^ integer not null,
+					^operations^ integer not null,
+					^abs_points^ integer not null,
+					primary key (^team^, ^name^)
+				)`,
+				"create index idx_user_totals_points on user_totals(^team^, ^points^)",
+				"insert into user_totals (^team^, ^name^, ^points^, ^operations^, ^abs_points^) select ^team^, ^to^, sum(^points^), count(*), sum(abs(^points^)) from karma group by ^team^, ^to^",
+			},
+			"postgres": {
+				`create table user_totals (
+					^team^ text not null,
+					^name^ text not null,
+					^points^ integer not null,
+					^operations^ integer not null,
+					^abs_points^ integer not null,
+					primary key (^team^, ^name^)
+				)`,
+				"create index idx_user_totals_points on user_totals(^team^, ^points^)",
+				"insert into user_totals (^team^, ^name^, ^points^, ^operations^, ^abs_points^) select ^team^, ^to^, sum(^points^), count(*), sum(abs(^points^)) from karma group by ^team^, ^to^",
+			},
+		},
+	},
+	{
+		version: 5,
+		name:    "add revoked_at to karma",
+		up: map[string][]string{
+			"sqlite3": {
+				"alter table karma add column ^revoked_at^ text",
+				"create index idx_team_from on karma(^team^, ^from^)",
+			},
+			"postgres": {
+				"alter table karma add column ^revoked_at^ text",
+				"create index idx_team_from on karma(^team^, ^from^)",
+			},
+		},
+	},
+	{
+		version: 6,
+		name:    "add kind to karma",
+		up: map[string][]string{
+			"sqlite3": {
+				"alter table karma add column ^kind^ text not null default ''",
+				"update karma set ^kind^ = case when exists (select 1 from users where users.^id^ = karma.^to^) then 'user' else 'thing' end",
+			},
+			"postgres": {
+				"alter table karma add column ^kind^ text not null default ''",
+				"update karma set ^kind^ = case when exists (select 1 from users where users.^id^ = karma.^to^) then 'user' else 'thing' end",
+			},
+		},
+	},
+	{
+		version: 7,
+		name:    "add channel to karma and create channels table",
+		up: map[string][]string{
+			"sqlite3": {
+				"alter table karma add column ^channel^ text not null default ''",
+				"create index idx_team_channel on karma(^team^, ^channel^)",
+				"create table channels (^id^ text primary key, ^team^ text not null default '', ^name^ text not null)",
+				"create index idx_channels_name on channels(lower(^name^))",
+			},
+			"postgres": {
+				"alter table karma add column ^channel^ text not null default ''",
+				"create index idx_team_channel on karma(^team^, ^channel^)",
+				"create table channels (^id^ text primary key, ^team^ text not null default '', ^name^ text not null)",
+				"create index idx_channels_name on channels(lower(^name^))",
+			},
+		},
+	},
+	{
+		version: 8,
+		name:    "key users and channels by team and id",
+		up: map[string][]string{
+			"sqlite3": {
+				"create table users_by_team (^id^ text not null, ^team^ text not null default '', ^name^ text not null, primary key (^team^, ^id^))",
+				"insert into users_by_team (^id^, ^team^, ^name^) select ^id^, ^team^, ^name^ from users",
+				"drop table users",
+				"alter table users_by_team rename to users",
+				"create index idx_users_name on users(^team^, lower(^name^))",
+				"create table channels_by_team (^id^ text not null, ^team^ text not null default '', ^name^ text not null, primary key (^team^, ^id^))",
+				"insert into channels_by_team (^id^, ^team^, ^name^) select ^id^, ^team^, ^name^ from channels",
+				"drop table channels",
+				"alter table channels_by_team rename to channels",
+				"create index idx_channels_name on channels(^team^, lower(^name^))",
+			},
+			"postgres": {
+				"alter table users drop constraint users_pkey",
+				"alter table users add primary key (^team^, ^id^)",
+				"drop index idx_users_name",
+				"create index idx_users_name on users(^team^, lower(^name^))",
+				"alter table channels drop constraint channels_pkey",
+				"alter table channels add primary key (^team^, ^id^)",
+				"drop index idx_channels_name",
+				"create index idx_channels_name on channels(^team^, lower(^name^))",
+			},
+		},
+	},
+}
+
+// beginMigrations starts a transaction in which the migrations
+// table can be read and written. It holds the dialect's migration
+// lock, which is taken before the migrations table is created so
+// that instances that start at the same time do not race to create
+// it.
+func (db *DB) beginMigrations(ctx context.Context) (*sql.Tx, error) {
+	tx, err := db.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if db.dialect.lockMigrations != "" {
+		_, err = tx.ExecContext(ctx, db.dialect.lockMigrations)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("create table if not exists schema_migrations (`version` integer primary key, `name` text not null, `applied_at` text not null)"))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// Migrate applies all pending migrations in order and returns
+// the ones that have been applied.
+func (db *DB) Migrate(ctx context.Context) ([]*MigrationStatus, error) {
+	var applied []*MigrationStatus
+	for _, m := range migrations {
+		status, err := db.applyMigration(ctx, m)
+		if err != nil {
+			return applied, err
+		}
+
+		if status != nil {
+			applied = append(applied, status)
+		}
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a migration inside a transaction. It returns
+// nil if the migration has already been applied.
+func (db *DB) applyMigration(ctx context.Context, m *migration) (*MigrationStatus, error) {
+	tx, err := db.beginMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx, db.dialect.rebind("select count(*) from schema_migrations where `version` = ?"), m.version).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, nil
+	}
+
+	for _, stmt := range m.up[db.dialect.driver] {
+		_, err = tx.ExecContext(ctx, strings.Replace(stmt, "^", db.dialect.quote, -1))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	status := &MigrationStatus{
+		Version:   m.version,
+		Name:      m.name,
+		Applied:   true,
+		AppliedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	_, err = tx.ExecContext(ctx, db.dialect.rebind("insert into schema_migrations (`version`, `name`, `applied_at`) values (?, ?, ?)"), status.Version, status.Name, status.AppliedAt.Format(timeFormat))
+	if err != nil {
+		return nil, err
+	}
+
+	return status, tx.Commit()
+}
+
+// MigrationStatus returns all known migrations along with
+// whether they have been applied to the database.
+func (db *DB) MigrationStatus(ctx context.Context) ([]*MigrationStatus, error) {
+	tx, err := db.beginMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]string)
+	for rows.Next() {
+		var (
+			version   int
+			timestamp string
+		)
+		err := rows.Scan(&version, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		appliedAt[version] = timestamp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status := &MigrationStatus{
+			Version: m.version,
+			Name:    m.name,
+		}
+
+		if timestamp, ok := appliedAt[m.version]; ok {
+			status.Applied = true
+			status.AppliedAt, err = time.Parse(timeFormat, timestamp)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		statuses[i] = status
+	}
+
+	return statuses, nil
+}
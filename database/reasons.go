@@ -0,0 +1,76 @@
+package database
+
+import (
+	"sort"
+	"strings"
+)
+
+// Reasons lists the most frequent reasons for which a user has
+// received karma.
+type Reasons []*Reason
+
+// A Reason is an entry in Reasons. Operations is the number of karma
+// operations that were given for the reason, and Points is the sum
+// of their points.
+type Reason struct {
+	Reason     string
+	Operations int
+	Points     int
+}
+
+// NormalizeReason returns the form of a reason that operations are
+// grouped by. Reasons that only differ in case, whitespace or
+// trailing punctuation are the same.
+func NormalizeReason(reason string) string {
+	reason = strings.ToLower(strings.Join(strings.Fields(reason), " "))
+
+	return strings.TrimRight(reason, ".!?")
+}
+
+// A ReasonCounter groups karma operations by their normalized
+// reason.
+type ReasonCounter map[string]*Reason
+
+// Add counts karma operations that were given for a reason.
+// Operations without a reason are ignored.
+func (c ReasonCounter) Add(reason string, operations, points int) {
+	reason = NormalizeReason(reason)
+	if reason == "" {
+		return
+	}
+
+	r, ok := c[reason]
+	if !ok {
+		r = &Reason{Reason: reason}
+		c[reason] = r
+	}
+
+	r.Operations += operations
+	r.Points += points
+}
+
+// Top returns the X most frequent reasons. Reasons that were given
+// equally often are ordered by their points.
+func (c ReasonCounter) Top(limit int) Reasons {
+	reasons := make(Reasons, 0, len(c))
+	for _, r := range c {
+		reasons = append(reasons, r)
+	}
+
+	sort.Slice(reasons, func(i, j int) bool {
+		switch {
+		case reasons[i].Operations != reasons[j].Operations:
+			return reasons[i].Operations > reasons[j].Operations
+		case reasons[i].Points != reasons[j].Points:
+			return reasons[i].Points > reasons[j].Points
+		default:
+			return reasons[i].Reason < reasons[j].Reason
+		}
+	})
+
+	if limit >= 0 && limit < len(reasons) {
+		reasons = reasons[:limit]
+	}
+
+	return reasons
+}
@@ -1,96 +1,42 @@
 package karmabot
 
 import (
-	"sort"
+	"context"
 	"time"
 
 	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/database/memdb"
 )
 
-type TestDatabase struct {
-	records []database.Points
-}
+var _ Database = (*memdb.DB)(nil)
 
-func (t *TestDatabase) InsertPoints(points *database.Points) error {
-	t.records = append(t.records, *points)
-	return nil
-}
+// testDatabase is an in-memory database that keeps a log of
+// the karma operations inserted into it.
+type testDatabase struct {
+	*memdb.DB
 
-func (t *TestDatabase) GetUser(name string) (*database.User, error) {
-	foundUser := false
-	pointCount := 0
-	for _, r := range t.records {
-		if r.To == name {
-			foundUser = true
-			pointCount += r.Points
-		}
-	}
-	if !foundUser {
-		return nil, database.ErrNoSuchUser
-	}
-	return &database.User{
-		Name:   name,
-		Points: pointCount,
-	}, nil
+	records []*database.Points
 }
 
-func (t *TestDatabase) GetLeaderboard(limit int) (database.Leaderboard, error) {
-	us := make(map[string]*database.User)
-
-	for _, r := range t.records {
-		u := us[r.To]
-		if u == nil {
-			u = &database.User{}
-		}
-		u.Points += r.Points
-		us[r.To] = u
+func newTestDatabase() *testDatabase {
+	db, err := memdb.New(&memdb.Config{})
+	if err != nil {
+		panic(err)
 	}
 
-	lb := make(database.Leaderboard, 0, len(us))
-	for _, u := range us {
-		lb = append(lb, u)
-	}
-	sort.SliceStable(lb, func(i, j int) bool {
-		ui := lb[i]
-		uj := lb[j]
-		if ui.Points == uj.Points && ui.Name < uj.Name {
-			return true
-		}
-		if ui.Points < uj.Points {
-			return true
-		}
-		return false
-	})
-	return lb[:limit], nil
+	return &testDatabase{DB: db}
 }
 
-func (t *TestDatabase) GetTotalPoints() (int, error) {
-	totalPoints := 0
-	for _, r := range t.records {
-		p := r.Points
-		if p < 0 {
-			p = -p
-		}
-		totalPoints += p
-	}
-	return totalPoints, nil
+func (t *testDatabase) InsertPoints(ctx context.Context, points *database.Points) error {
+	t.records = append(t.records, points)
+
+	return t.DB.InsertPoints(ctx, points)
 }
 
-func (t *TestDatabase) GetThrowback(user string) (*database.Throwback, error) {
-	foundUser := false
-	var points database.Points
-	for _, r := range t.records {
-		if r.To == user {
-			foundUser = true
-			points = r
-		}
-	}
-	if !foundUser {
-		return nil, database.ErrNoSuchUser
-	}
+// insertAt inserts points as if they had been given at timestamp.
+func (t *testDatabase) insertAt(points *database.Points, timestamp time.Time) {
+	t.Config.Now = func() time.Time { return timestamp }
+	defer func() { t.Config.Now = nil }()
 
-	return &database.Throwback{
-		Points:    points,
-		Timestamp: time.Now(),
-	}, nil
+	t.InsertPoints(context.Background(), points)
 }
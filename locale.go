@@ -0,0 +1,111 @@
+package karmabot
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// defaultLocale is the locale that karmabot replies in unless
+// another one is configured.
+const defaultLocale = "en"
+
+// A locale translates karmabot's replies into a language. Replies
+// are written in English and looked up in the locale's messages, so
+// English needs no messages at all.
+type locale struct {
+	// messages maps English format strings to translated ones.
+	// Messages that are missing are sent in English.
+	messages map[string]string
+
+	// magnitudes, ago and fromNow format relative times. Locales
+	// without magnitudes use go-humanize's English ones.
+	magnitudes   []humanize.RelTimeMagnitude
+	ago, fromNow string
+}
+
+// locales are the locales that karmabot ships with, keyed by their
+// ISO 639-1 code.
+var locales = map[string]*locale{
+	"en": {},
+	"de": german,
+}
+
+// IsLocale reports whether karmabot can reply in a locale.
+func IsLocale(name string) bool {
+	_, ok := locales[name]
+	return ok
+}
+
+// Locales returns the codes of every locale that karmabot can
+// reply in.
+func Locales() []string {
+	var names []string
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Sprintf translates a format string and formats it.
+func (l *locale) Sprintf(format string, args ...interface{}) string {
+	if translated, ok := l.messages[format]; ok {
+		format = translated
+	}
+
+	return fmt.Sprintf(format, args...)
+}
+
+// motivateReason is the reason of the points given by motivate.
+const motivateReason = "doing good work"
+
+// reactjiReason matches the reasons of reactji votes, which start
+// with the name of the user who voted.
+var reactjiReason = regexp.MustCompile(`^(.+) (added|removed) a :([^:\s]+): reactji$`)
+
+// Reason translates the reason of a karma operation. The reasons
+// that karmabot gives itself are stored in English and translated
+// when they are shown, and reasons given by users are left as they
+// are.
+func (l *locale) Reason(reason string) string {
+	if reason == motivateReason {
+		return l.Sprintf(motivateReason)
+	}
+
+	match := reactjiReason.FindStringSubmatch(reason)
+	switch {
+	case match == nil:
+		return reason
+	case match[2] == "added":
+		return match[1] + " " + l.Sprintf("added a :%s: reactji", match[3])
+	default:
+		return match[1] + " " + l.Sprintf("removed a :%s: reactji", match[3])
+	}
+}
+
+// Time formats a time relative to now, e.g. "3 days ago".
+func (l *locale) Time(then time.Time) string {
+	if l.magnitudes == nil {
+		return humanize.Time(then)
+	}
+
+	return humanize.CustomRelTime(then, time.Now(), l.ago, l.fromNow, l.magnitudes)
+}
+
+// locale returns the locale that karmabot replies in in a channel.
+// Channels without a locale of their own use the bot's locale.
+func (b *Bot) locale(channel string) *locale {
+	if l, ok := locales[b.Config.ChannelLocales[channel]]; ok {
+		return l
+	}
+	if l, ok := locales[b.Config.Locale]; ok {
+		return l
+	}
+
+	return locales[defaultLocale]
+}
@@ -0,0 +1,144 @@
+package karmabot
+
+import (
+	"math"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// german translates karmabot's replies into German. Durations are in
+// the dative case because they follow "nach jeweils" and "innerhalb
+// von".
+var german = &locale{
+	messages: map[string]string{
+		// errors
+		"an error has occurred.":                 "ein Fehler ist aufgetreten.",
+		"no such user":                           "diesen Benutzer gibt es nicht",
+		"Sorry, you are not allowed to do that.": "Das darfst du leider nicht.",
+
+		// karma operations
+		" for %s":                " für %s",
+		"for %s":                 "für %s",
+		"doing good work":        "gute Arbeit",
+		"undid %+d":              "%+d rückgängig gemacht",
+		"added a :%s: reactji":   "hat ein :%s: Reactji hinzugefügt",
+		"removed a :%s: reactji": "hat ein :%s: Reactji entfernt",
+		"you do not have any recent karma operations to undo.": "du hast keine aktuellen Karma-Operationen, die du rückgängig machen kannst.",
+
+		// throwbacks and history
+		"could not find any karma operations for %s":  "keine Karma-Operationen für %s gefunden",
+		"%s received %d points from %s %s%s":          "%[1]s hat %[4]s %[2]d Punkte von %[3]s erhalten%[5]s",
+		"*%s* received %d points from *%s*":           "*%[1]s* hat %[2]d Punkte von *%[3]s* erhalten",
+		"<!date^%d^{date_short_pretty} at {time}|%s>": "<!date^%d^{date_short_pretty} um {time}|%s>",
+		"*karma history for %s*\n":                    "*Karma-Verlauf von %s*\n",
+		"%+d from %s %s":                              "%+d von %s %s",
+
+		// reasons
+		"could not find any reasons for %s":     "keine Gründe für %s gefunden",
+		"*top reasons for %s*\n":                "*häufigste Gründe für %s*\n",
+		"%d. %s: %+d points in %d operations\n": "%d. %s: %+d Punkte in %d Operationen\n",
+		"show why a user has received karma":    "anzeigen, wofür ein Benutzer Karma erhalten hat",
+		"lists the %d reasons for which a user, or you if you do not pass a user, has received karma most often, unless you pass another number. reasons that only differ in case are counted together.": "listet die %d Gründe auf, für die ein Benutzer, oder du, wenn du keinen Benutzer angibst, am häufigsten Karma erhalten hat, sofern du keine andere Zahl angibst. Gründe, die sich nur in der Groß- und Kleinschreibung unterscheiden, werden zusammengezählt.",
+
+		// leaderboards
+		"leaderboard":       "Bestenliste",
+		"people":            "Personen",
+		"things":            "Dinge",
+		"*top %d %s*":       "*Top %d %s*",
+		"*top %d %s %s*":    "*Top %d %s %s*",
+		"%s in <#%s>":       "%s in <#%s>",
+		"%d points":         "%d Punkte",
+		"*top %d givers*\n": "*Top %d Geber*\n",
+		"%d. %s gave %d points in %d operations\n": "%d. %s hat %d Punkte in %d Operationen vergeben\n",
+		"Previous": "Zurück",
+		"Next":     "Weiter",
+
+		// budgets
+		"per day":  "pro Tag",
+		"per week": "pro Woche",
+		"you have %d of %d points left to give today.":       "du kannst heute noch %d von %d Punkten vergeben.",
+		"you have %d of %d points left to give this week.":   "du kannst diese Woche noch %d von %d Punkten vergeben.",
+		"there is no limit to how many points you can give.": "du kannst beliebig viele Punkte vergeben.",
+
+		// home tab
+		"Your karma":                           "Dein Karma",
+		"you have not received any karma yet.": "du hast noch kein Karma erhalten.",
+		"*Points*\n%d":                         "*Punkte*\n%d",
+		"*Rank*\n#%d":                          "*Rang*\n#%d",
+		"*recently received*":                  "*kürzlich erhalten*",
+
+		// slash commands
+		commandUsage: "Verwendung: `/karma <user>++ [for <reason>]`, `/karma <user>`, `/karma top [n]`, `/karma throwback [user]` oder `/karma url`. sende `/karma help`, um alle Befehle aufzulisten",
+
+		// help
+		"*karmabot commands*\n":                                                             "*karmabot-Befehle*\n",
+		"karmabot replies in a thread under your message.\n":                                "karmabot antwortet in einem Thread unter deiner Nachricht.\n",
+		"karmabot's replies are only visible to you.\n":                                     "die Antworten von karmabot sind nur für dich sichtbar.\n",
+		"send `%[1]s help <command>` to learn more about a command, e.g. `%[1]s help top`.": "sende `%[1]s help <command>`, um mehr über einen Befehl zu erfahren, z. B. `%[1]s help top`.",
+		"there is no %q command. send `%s help` to list all commands.":                      "es gibt keinen Befehl %q. sende `%s help`, um alle Befehle aufzulisten.",
+		"react to a message":                                                                "auf eine Nachricht reagieren",
+		"%s or %s":                                                                          "%s oder %s",
+		"give a user a point for doing good work":                                           "einem Benutzer einen Punkt für gute Arbeit geben",
+		"link to the web UI":                                                                "Link zur Weboberfläche",
+		"give or take karma":                                                                "Karma geben oder nehmen",
+		"show the leaderboard":                                                              "die Bestenliste anzeigen",
+		"show who has given the most karma":                                                 "anzeigen, wer das meiste Karma vergeben hat",
+		"show a random karma operation":                                                     "eine zufällige Karma-Operation anzeigen",
+		"list the latest karma operations":                                                  "die letzten Karma-Operationen auflisten",
+		"undo your latest karma operation":                                                  "deine letzte Karma-Operation rückgängig machen",
+		"show how many points you have left to give":                                        "anzeigen, wie viele Punkte du noch vergeben kannst",
+		"upvote or downvote the author of a message":                                        "dem Autor einer Nachricht einen Punkt geben oder nehmen",
+		"show this help, or more about a command":                                           "diese Hilfe oder mehr über einen Befehl anzeigen",
+		"show a user's points":                                                              "die Punkte eines Benutzers anzeigen",
+		"this is the same as `<user>++ for doing good work`. see <http://motivate.im/|motivate.im>.":                                                "das ist dasselbe wie `<user>++ for doing good work`. siehe <http://motivate.im/|motivate.im>.",
+		"the web UI lists the leaderboard for any period of time. it is available at %s.":                                                           "die Weboberfläche zeigt die Bestenliste für beliebige Zeiträume. sie ist unter %s erreichbar.",
+		"every extra `+` or `-` gives or takes another point, up to %d points at once. e.g. `alice+++ for the launch` gives alice 2 points.\n":      "jedes weitere `+` oder `-` gibt oder nimmt einen weiteren Punkt, bis zu %d Punkte auf einmal. z. B. gibt `alice+++ for the launch` alice 2 Punkte.\n",
+		"you can give karma to several users at once: `alice++ bob++ for the launch`.\n":                                                            "du kannst mehreren Benutzern auf einmal Karma geben: `alice++ bob++ for the launch`.\n",
+		"karma can be given to anything, not only people, e.g. `coffee++`.":                                                                         "Karma kann allem gegeben werden, nicht nur Personen, z. B. `coffee++`.",
+		"\nyou cannot give karma to yourself.":                                                                                                      "\ndu kannst dir nicht selbst Karma geben.",
+		"\nyou can give or take up to %d points %s. send `%s budget` to see how many you have left.":                                                "\ndu kannst bis zu %d Punkte %s geben oder nehmen. sende `%s budget`, um zu sehen, wie viele dir noch bleiben.",
+		"lists the top %d users unless you pass another number.\n":                                                                                  "listet die besten %d Benutzer auf, sofern du keine andere Zahl angibst.\n",
+		"`people` and `things` only list Slack users or everything else, and `here` only counts karma given in the current channel.\n":              "`people` und `things` listen nur Slack-Benutzer bzw. alles andere auf, und `here` zählt nur Karma, das im aktuellen Channel vergeben wurde.\n",
+		"the period can be `today`, `this <day|week|month|year>`, `last <day|week|month|year>` or `since <YYYY-MM-DD>`, e.g. `%s top 5 this week`.": "der Zeitraum kann `today`, `this <day|week|month|year>`, `last <day|week|month|year>` oder `since <YYYY-MM-DD>` sein, z. B. `%s top 5 this week`.",
+		"\npoints lose half of their value every %s. append `raw` to rank users by their all-time totals instead.":                                  "\nPunkte verlieren nach jeweils %s die Hälfte ihres Werts. hänge `raw` an, um Benutzer stattdessen nach ihren Gesamtpunkten zu ordnen.",
+		"lists the top %d users who have given the most points to others, unless you pass another number.":                                          "listet die %d Benutzer auf, die anderen die meisten Punkte gegeben haben, sofern du keine andere Zahl angibst.",
+		"shows a random karma operation on a user, or on you if you do not pass a user.":                                                            "zeigt eine zufällige Karma-Operation für einen Benutzer, oder für dich, wenn du keinen Benutzer angibst.",
+		"lists the latest %d karma operations on a user, or on you if you do not pass a user, unless you pass another number.":                      "listet die letzten %d Karma-Operationen für einen Benutzer auf, oder für dich, wenn du keinen Benutzer angibst, sofern du keine andere Zahl angibst.",
+		"undoes the latest karma operation that you performed within the last %s.":                                                                  "macht deine letzte Karma-Operation rückgängig, wenn du sie innerhalb von %s zurücknimmst.",
+		"everyone can give or take up to %d points %s. operations that exceed your budget are reduced to the points that you have left.":            "jeder kann bis zu %d Punkte %s geben oder nehmen. Operationen, die dein Budget überschreiten, werden auf die verbleibenden Punkte reduziert.",
+		"react to a message with %s to give its author a point, or with %s to take one. removing the reactji undoes the vote.":                      "reagiere auf eine Nachricht mit %s, um ihrem Autor einen Punkt zu geben, oder mit %s, um ihm einen zu nehmen. wenn du das Reactji entfernst, wird die Stimme zurückgenommen.",
+		"lists every command, or explains a single command, e.g. `%s help top`.":                                                                    "listet alle Befehle auf oder erklärt einen einzelnen Befehl, z. B. `%s help top`.",
+		"shows how many points a user has.": "zeigt, wie viele Punkte ein Benutzer hat.",
+		"\npoints lose half of their value every %s. send `<user>== raw` to see their all-time total instead.": "\nPunkte verlieren nach jeweils %s die Hälfte ihres Werts. sende `<user>== raw`, um stattdessen die Gesamtpunkte zu sehen.",
+
+		// durations
+		"day":        "einem Tag",
+		"%d days":    "%d Tagen",
+		"hour":       "einer Stunde",
+		"%d hours":   "%d Stunden",
+		"minute":     "einer Minute",
+		"%d minutes": "%d Minuten",
+	},
+	magnitudes: []humanize.RelTimeMagnitude{
+		{D: time.Second, Format: "jetzt", DivBy: time.Second},
+		{D: 2 * time.Second, Format: "%s einer Sekunde", DivBy: 1},
+		{D: time.Minute, Format: "%s %d Sekunden", DivBy: time.Second},
+		{D: 2 * time.Minute, Format: "%s einer Minute", DivBy: 1},
+		{D: time.Hour, Format: "%s %d Minuten", DivBy: time.Minute},
+		{D: 2 * time.Hour, Format: "%s einer Stunde", DivBy: 1},
+		{D: humanize.Day, Format: "%s %d Stunden", DivBy: time.Hour},
+		{D: 2 * humanize.Day, Format: "%s einem Tag", DivBy: 1},
+		{D: humanize.Week, Format: "%s %d Tagen", DivBy: humanize.Day},
+		{D: 2 * humanize.Week, Format: "%s einer Woche", DivBy: 1},
+		{D: humanize.Month, Format: "%s %d Wochen", DivBy: humanize.Week},
+		{D: 2 * humanize.Month, Format: "%s einem Monat", DivBy: 1},
+		{D: humanize.Year, Format: "%s %d Monaten", DivBy: humanize.Month},
+		{D: 18 * humanize.Month, Format: "%s einem Jahr", DivBy: 1},
+		{D: 2 * humanize.Year, Format: "%s 2 Jahren", DivBy: 1},
+		{D: humanize.LongTime, Format: "%s %d Jahren", DivBy: humanize.Year},
+		{D: math.MaxInt64, Format: "%s langer Zeit", DivBy: 1},
+	},
+	ago:     "vor",
+	fromNow: "in",
+}
@@ -0,0 +1,138 @@
+package karmabot
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// formatVerbs matches the verbs of a format string, along with
+// explicit argument indexes.
+var formatVerbs = regexp.MustCompile(`%(?:\[\d+\])?[+#]?[a-z]`)
+
+// verbs returns the verbs of a format string without their argument
+// indexes, sorted.
+func verbs(format string) []string {
+	var verbs []string
+	for _, verb := range formatVerbs.FindAllString(format, -1) {
+		verbs = append(verbs, regexp.MustCompile(`\[\d+\]`).ReplaceAllString(verb, ""))
+	}
+	sort.Strings(verbs)
+
+	return verbs
+}
+
+func TestLocaleMessages(t *testing.T) {
+	for name, l := range locales {
+		for format, translated := range l.messages {
+			if !reflect.DeepEqual(verbs(format), verbs(translated)) {
+				t.Errorf("%s translation %q of %q has verbs %v; want %v", name, translated, format, verbs(translated), verbs(format))
+			}
+		}
+	}
+}
+
+func TestLocaleTime(t *testing.T) {
+	then := time.Now().Add(-3*24*time.Hour - time.Minute)
+
+	for name, want := range map[string]string{
+		"en": "3 days ago",
+		"de": "vor 3 Tagen",
+	} {
+		if got := locales[name].Time(then); got != want {
+			t.Errorf("%s time = %q; want %q", name, got, want)
+		}
+	}
+}
+
+func TestChannelLocales(t *testing.T) {
+	b, cs, _ := newBot(&Config{
+		UI:               blankui.New(),
+		LeaderboardLimit: 10,
+		Locale:           "de",
+		ChannelLocales:   map[string]string{"english": "en"},
+	})
+
+	for _, channel := range []string{"channel", "english"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    "karma top 1",
+			Channel: channel,
+			User:    "U9876",
+		})
+	}
+
+	want := []string{
+		"*Top 1 Bestenliste*\n1. önehundred_points == 100\n",
+		"*top 1 leaderboard*\n1. önehundred_points == 100\n",
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent messages %+v; want %d messages", cs.SentMessages, len(want))
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Text != want[i] {
+			t.Errorf("sent message %q to %s; want %q", msg.Text, msg.Channel, want[i])
+		}
+	}
+}
+
+func TestLocaleReason(t *testing.T) {
+	tests := []struct {
+		reason, en, de string
+	}{
+		{"doing good work", "doing good work", "gute Arbeit"},
+		{"alice added a :+1: reactji", "alice added a :+1: reactji", "alice hat ein :+1: Reactji hinzugefügt"},
+		{"alice removed a :-1: reactji", "alice removed a :-1: reactji", "alice hat ein :-1: Reactji entfernt"},
+		{"the launch", "the launch", "the launch"},
+		{"added a reactji", "added a reactji", "added a reactji"},
+	}
+	for _, tt := range tests {
+		if got := locales["en"].Reason(tt.reason); got != tt.en {
+			t.Errorf("en reason for %q = %q; want %q", tt.reason, got, tt.en)
+		}
+		if got := locales["de"].Reason(tt.reason); got != tt.de {
+			t.Errorf("de reason for %q = %q; want %q", tt.reason, got, tt.de)
+		}
+	}
+}
+
+func TestStoredReasons(t *testing.T) {
+	b, _, db := newBot(&Config{
+		UI:       blankui.New(),
+		Motivate: true,
+		Locale:   "de",
+		Reactji:  &ReactjiConfig{Enabled: true, Upvote: StringList{"+1": struct{}{}}, Downvote: make(StringList)},
+	})
+
+	// reasons are stored untranslated so that every channel can
+	// show them in its own locale
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "?m alice",
+		Channel: "channel",
+		User:    "U9876",
+	})
+	b.handleReactionAddedEvent(context.Background(), &slackevents.ReactionAddedEvent{
+		Type:     "reaction_added",
+		User:     "U9876",
+		ItemUser: "onehundred_points",
+		Reaction: "+1",
+	})
+
+	// the first record is the one that newBot inserts
+	want := []string{"doing good work", "U9876 added a :+1: reactji"}
+	if len(db.records) != len(want)+1 {
+		t.Fatalf("stored %d records; want %d", len(db.records)-1, len(want))
+	}
+	for i, record := range db.records[1:] {
+		if record.Reason != want[i] {
+			t.Errorf("stored reason %q; want %q", record.Reason, want[i])
+		}
+	}
+}
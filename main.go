@@ -1,51 +1,76 @@
 package karmabot
 
 import (
+	"context"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/aybabtme/log"
 	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/munge"
 	"github.com/kamaln7/karmabot/ui"
-	"github.com/aybabtme/log"
 	"github.com/slack-go/slack"
-	"github.com/slack-go/slack/socketmode"
 	"github.com/slack-go/slack/slackevents"
-	"github.com/kamaln7/karmabot/munge"
-	"github.com/dustin/go-humanize"
-)
-
-var (
-	regexps = struct {
-		Motivate, GiveKarma, QueryKarma, Leaderboard, URL, SlackUser, Throwback *regexp.Regexp
-	}{
-		Motivate:    karmaReg.GetMotivate(),
-		GiveKarma:   karmaReg.GetGive(),
-		QueryKarma:  karmaReg.GetQuery(),
-		Leaderboard: regexp.MustCompile(`^karma(?:bot)? (?:leaderboard|top|highscores) ?([0-9]+)?$`),
-		URL:         regexp.MustCompile(`^karma(?:bot)? (?:url|web|link)?$`),
-		SlackUser:   regexp.MustCompile(`^<@([A-Za-z0-9]+)>$`),
-		Throwback:   karmaReg.GetThrowback(),
-	}
+	"github.com/slack-go/slack/socketmode"
 )
 
 // Database is an abstraction around the database, mostly designed for use in tests.
 type Database interface {
 	// InsertPoints persistently records that points have been given or deducted.
-	InsertPoints(points *database.Points) error
+	InsertPoints(ctx context.Context, points *database.Points) error
+
+	// GetUser returns information about a user, including their current number of points,
+	// taking only the records that match the filter into account.
+	GetUser(ctx context.Context, name string, filter *database.Filter) (*database.User, error)
+
+	// GetLeaderboard returns the top X users with the most points, in order,
+	// taking only the records that match the filter into account.
+	GetLeaderboard(ctx context.Context, limit int, filter *database.Filter) (database.Leaderboard, error)
 
-	// GetUser returns information about a user, including their current number of points.
-	GetUser(name string) (*database.User, error)
+	// GetRank returns the position of a user on the leaderboard, taking only
+	// the records that match the filter into account.
+	GetRank(ctx context.Context, name string, filter *database.Filter) (int, error)
 
-	// GetLeaderboard returns the top X users with the most points, in order.
-	GetLeaderboard(limit int) (database.Leaderboard, error)
+	// GetTotalPoints returns the total number of points transferred across all users
+	// in the records that match the filter.
+	GetTotalPoints(ctx context.Context, filter *database.Filter) (int, error)
 
-	// GetTotalPoints returns the total number of points transferred across all users.
-	GetTotalPoints() (int, error)
+	// GetGivers returns the top X users who have given the most points, in order.
+	GetGivers(ctx context.Context, limit int) (database.Givers, error)
 
 	// GetThrowback returns a random karma operation on a specific user.
-	GetThrowback(user string) (*database.Throwback, error)
+	GetThrowback(ctx context.Context, user string) (*database.Throwback, error)
+
+	// GetPointsGiven returns the number of points that a user has given or taken since a specific time.
+	// Points given to and taken from the same recipient cancel each other out.
+	GetPointsGiven(ctx context.Context, from string, since time.Time) (int, error)
+
+	// GetPointsGivenTo returns the net number of points that a user has given to a recipient since a specific time.
+	GetPointsGivenTo(ctx context.Context, from, to string, since time.Time) (int, error)
+
+	// SetUserName caches the username of the Slack user with the passed ID.
+	SetUserName(ctx context.Context, id, name string) error
+
+	// GetUserID returns the ID of a Slack user by their cached username.
+	GetUserID(ctx context.Context, name string) (string, error)
+
+	// SetChannelName caches the name of the Slack channel with the passed ID.
+	SetChannelName(ctx context.Context, id, name string) error
+
+	// GetChannelID returns the ID of a Slack channel by its cached name.
+	GetChannelID(ctx context.Context, name string) (string, error)
+
+	// GetHistory returns the latest karma operations on a specific user, newest first.
+	GetHistory(ctx context.Context, user string, limit, offset int) ([]*database.Throwback, error)
+
+	// GetReasons returns the most frequent reasons for which a user has received karma.
+	GetReasons(ctx context.Context, user string, limit int) (database.Reasons, error)
+
+	// RevokeLast revokes the latest karma operation performed by a user since a specific time.
+	RevokeLast(ctx context.Context, from string, since time.Time) (*database.Points, error)
 }
 
 type ChatService interface {
@@ -55,20 +80,35 @@ type ChatService interface {
 	// Returns Socketmode client
 	GetSocketClient() *socketmode.Client
 
-    // SendMessage sends a message to a Slack channel.
-    SendMessage(channel, text string, options ...slack.MsgOption) (string, string, error)
+	// Ack acknowledges a Socket Mode request.
+	Ack(req socketmode.Request)
+
+	// SendMessage sends a message to a Slack channel.
+	SendMessage(channel, text string, options ...slack.MsgOption) (string, string, error)
 
-    // PostEphemeral sends an ephemeral message to a user in a channel.
-    PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error)
+	// PostEphemeral sends an ephemeral message to a user in a channel.
+	PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error)
 
 	// GetUserInfo retrieves the complete user information for the specified username.
 	GetUserInfo(user string) (*slack.User, error)
+
+	// GetConversationInfo retrieves information about the specified channel.
+	GetConversationInfo(channel string) (*slack.Channel, error)
+
+	// Respond replies to a slash command through its response URL.
+	Respond(responseURL string, msg *slack.WebhookMessage) error
+
+	// PublishView publishes a user's Home tab.
+	PublishView(userID string, view slack.HomeTabViewRequest) error
+
+	// UpdateMessage replaces the contents of a message.
+	UpdateMessage(channel, timestamp, text string, options ...slack.MsgOption) error
 }
 
 // New chat code
 type SlackChatService struct {
-	Client  socketmode.Client
-	API    	*slack.Client
+	Client socketmode.Client
+	API    *slack.Client
 }
 
 // IncomingEventsChan returns a channel of real-time messaging events.
@@ -81,20 +121,46 @@ func (s SlackChatService) GetSocketClient() *socketmode.Client {
 	return &s.Client
 }
 
+// Ack acknowledges a Socket Mode request.
+func (s SlackChatService) Ack(req socketmode.Request) {
+	s.Client.Ack(req)
+}
+
 // SendMessage sends a message to a Slack channel.
 func (s SlackChatService) SendMessage(channel, text string, options ...slack.MsgOption) (string, string, error) {
-    return s.API.PostMessage(channel, append([]slack.MsgOption{slack.MsgOptionText(text, false)}, options...)...)
+	return s.API.PostMessage(channel, append([]slack.MsgOption{slack.MsgOptionText(text, false)}, options...)...)
 }
 
-
 // PostEphemeral sends an ephemeral message to a user in a channel.
 func (s SlackChatService) PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error) {
-    return s.API.PostEphemeral(channelID, userID, options...)
+	return s.API.PostEphemeral(channelID, userID, options...)
 }
 
 // GetUserInfo retrieves the complete user information for the specified username.
 func (s SlackChatService) GetUserInfo(user string) (*slack.User, error) {
-    return s.API.GetUserInfo(user)
+	return s.API.GetUserInfo(user)
+}
+
+// GetConversationInfo retrieves information about the specified channel.
+func (s SlackChatService) GetConversationInfo(channel string) (*slack.Channel, error) {
+	return s.API.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channel})
+}
+
+// Respond replies to a slash command through its response URL.
+func (s SlackChatService) Respond(responseURL string, msg *slack.WebhookMessage) error {
+	return slack.PostWebhook(responseURL, msg)
+}
+
+// UpdateMessage replaces the contents of a message.
+func (s SlackChatService) UpdateMessage(channel, timestamp, text string, options ...slack.MsgOption) error {
+	_, _, _, err := s.API.UpdateMessage(channel, timestamp, append([]slack.MsgOption{slack.MsgOptionText(text, false)}, options...)...)
+	return err
+}
+
+// PublishView publishes a user's Home tab.
+func (s SlackChatService) PublishView(userID string, view slack.HomeTabViewRequest) error {
+	_, err := s.API.PublishView(userID, view, "")
+	return err
 }
 
 // UserAliases is a map of alias -> main username
@@ -110,6 +176,7 @@ type Config struct {
 	Slack                       ChatService
 	Debug, Motivate, SelfKarma  bool
 	MaxPoints, LeaderboardLimit int
+	UndoWindow                  time.Duration
 	Log                         *log.Log
 	UI                          ui.Provider
 	DB                          Database
@@ -117,21 +184,109 @@ type Config struct {
 	Aliases                     UserAliases
 	Reactji                     *ReactjiConfig
 	ReplyType                   string
+	// PublicCommands makes replies to slash commands visible to
+	// everyone in the channel. By default, only the user who ran
+	// the command can see them.
+	PublicCommands bool
+	// Team is the ID of the Slack team that the bot serves. Events
+	// from other teams are ignored. An empty Team accepts all events.
+	Team string
+	// Budget is the number of points that every user can give or
+	// take per BudgetPeriod, which is either "day" or "week". A zero
+	// Budget does not limit users. Budgets are best-effort: the
+	// remaining budget is checked before every operation rather than
+	// in the same transaction, so operations that are handled at the
+	// same time can overspend it slightly.
+	Budget       int
+	BudgetPeriod string
+	// HalfLife makes karma decay over time: points lose half of
+	// their value every HalfLife. Leaderboards and user queries
+	// report decayed scores unless raw totals are asked for. A
+	// zero HalfLife disables decay.
+	HalfLife time.Duration
+	// Templates customize replies. Nil Templates keep the
+	// default replies.
+	Templates *Templates
+	// Locale is the language that karmabot replies in, e.g. "en"
+	// or "de". ChannelLocales overrides it for single channels,
+	// keyed by channel ID.
+	Locale         string
+	ChannelLocales map[string]string
+	// Triggers are the words that commands such as `karma top`
+	// start with. The first one is used in help texts. Defaults to
+	// karma and karmabot.
+	Triggers []string
+	// BotUserID is the Slack user ID of the bot. If it is set,
+	// commands can also start with a mention of the bot, e.g.
+	// `@karmabot top`.
+	BotUserID string
 }
 
+// defaultHistoryLimit is the amount of karma operations
+// that `karma history` lists by default.
+const defaultHistoryLimit = 10
+
+// maxListLimit is the most entries that lists such as `karma history`
+// can have, so that replies stay within Slack's message limits.
+const maxListLimit = 50
+
+// defaultReasonsLimit is the amount of reasons that
+// `karma reasons` lists by default.
+const defaultReasonsLimit = 5
+
 type Bot struct {
 	Config *Config
+
+	// trigger is the word that the bot's help texts start commands
+	// with.
+	trigger string
+
+	// regexps are the regular expressions built from the bot's
+	// trigger words.
+	regexps *botRegexps
+
+	// chatCommands are the commands that the bot responds to.
+	chatCommands []*chatCommand
+
+	// commands maps the message events that slash commands are
+	// translated into to the commands' response URLs.
+	commands sync.Map
 }
 
 func NewBot(config *Config) *Bot {
-	return &Bot{
-		Config: config,
+	triggers := config.Triggers
+	if len(triggers) == 0 {
+		triggers = defaultTriggers
+	}
+
+	b := &Bot{
+		Config:  config,
+		trigger: triggers[0],
+		regexps: newBotRegexps(triggers, config.BotUserID),
 	}
+	b.chatCommands = newChatCommands(b.trigger, b.regexps)
+
+	return b
 }
 
-func (b *Bot) Listen(){
-    for msg := range b.Config.Slack.IncomingEventsChan() {
-        fmt.Printf("Event received: %v\n", msg)
+// Listen handles incoming events until the events channel is closed
+// or ctx is cancelled. Event handlers inherit ctx, so cancelling it
+// also cancels their pending database queries.
+func (b *Bot) Listen(ctx context.Context) {
+	events := b.Config.Slack.IncomingEventsChan()
+	for {
+		var msg socketmode.Event
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-events:
+			if !ok {
+				return
+			}
+			msg = m
+		}
+
+		fmt.Printf("Event received: %v\n", msg)
 		switch msg.Type {
 		case socketmode.EventTypeConnected:
 			b.Config.Log.Info("Connected to Slack with Socket Mode.")
@@ -145,89 +300,116 @@ func (b *Bot) Listen(){
 
 				continue
 			}
-			b.Config.Slack.GetSocketClient().Ack(*msg.Request)
+			b.Config.Slack.Ack(*msg.Request)
+
+			if b.Config.Team != "" && eventsAPIEvent.TeamID != b.Config.Team {
+				b.Config.Log.KV("eventTeam", eventsAPIEvent.TeamID).Info("ignoring event from another team")
+				continue
+			}
 
 			switch eventsAPIEvent.Type {
 			case slackevents.CallbackEvent:
 				innerEvent := eventsAPIEvent.InnerEvent
-                b.Config.Log.KV("info", innerEvent).Info("Inner event received")
+				b.Config.Log.KV("info", innerEvent).Info("Inner event received")
 				//Handle slack events
 				switch ev := innerEvent.Data.(type) {
 				case *slackevents.MessageEvent:
 					fmt.Println("==========MESSAGE EVENT==========")
-					go b.handleMessageEvent(ev)
+					go b.handleMessageEvent(ctx, ev)
 				case *slackevents.ReactionAddedEvent:
 					fmt.Printf("reaction %q added to message %q", ev.Reaction, ev.ItemUser)
-					go b.handleReactionAddedEvent(ev)
+					go b.handleReactionAddedEvent(ctx, ev)
 				case *slackevents.ReactionRemovedEvent:
 					fmt.Printf("reaction %q removed from message %q", ev.Reaction, ev.ItemUser)
-					go b.handleReactionRemovedEvent(ev)
+					go b.handleReactionRemovedEvent(ctx, ev)
+				case *slackevents.AppHomeOpenedEvent:
+					go b.handleAppHomeOpenedEvent(ctx, ev)
 				}
 			default:
 				b.Config.Slack.GetSocketClient().Debugf("unsupported Events API event received")
-			}		
-		default:
-            fmt.Printf("Unhandled event type: %v\n", msg.Type)
-        }
-    }
-}
-
-func (b *Bot) handleMessageEvent(ev *slackevents.MessageEvent) {
-	if ev.Type != "message" {
-		return
-	}
+			}
+		case socketmode.EventTypeSlashCommand:
+			cmd, ok := msg.Data.(slack.SlashCommand)
+			if !ok {
+				b.Config.Slack.GetSocketClient().Debugf("ignored %+v", msg)
 
-	// convert motivates into karmabot syntax
-	if b.Config.Motivate {
-		if match := regexps.Motivate.FindStringSubmatch(ev.Text); len(match) > 0 {
-			ev.Text = match[1] + "++ for doing good work"
-		}
-	}
+				continue
+			}
+			b.Config.Slack.Ack(*msg.Request)
 
-	switch {
-	case regexps.URL.MatchString(ev.Text):
-		b.printURL(ev)
+			if b.Config.Team != "" && cmd.TeamID != b.Config.Team {
+				b.Config.Log.KV("eventTeam", cmd.TeamID).Info("ignoring slash command from another team")
+				continue
+			}
 
-	case regexps.GiveKarma.MatchString(ev.Text):
-		b.givePoints(ev)
+			go b.handleSlashCommand(ctx, &cmd)
+		case socketmode.EventTypeInteractive:
+			callback, ok := msg.Data.(slack.InteractionCallback)
+			if !ok {
+				b.Config.Slack.GetSocketClient().Debugf("ignored %+v", msg)
 
-	case regexps.Leaderboard.MatchString(ev.Text):
-		b.printLeaderboard(ev)
+				continue
+			}
+			b.Config.Slack.Ack(*msg.Request)
 
-	case regexps.Throwback.MatchString(ev.Text):
-		b.getThrowback(ev)
+			if b.Config.Team != "" && callback.Team.ID != b.Config.Team {
+				b.Config.Log.KV("eventTeam", callback.Team.ID).Info("ignoring interaction from another team")
+				continue
+			}
 
-	case regexps.QueryKarma.MatchString(ev.Text):
-		b.queryKarma(ev)
+			go b.handleInteraction(ctx, &callback)
+		default:
+			fmt.Printf("Unhandled event type: %v\n", msg.Type)
+		}
 	}
 }
 
+func (b *Bot) handleMessageEvent(ctx context.Context, ev *slackevents.MessageEvent) {
+	if ev.Type != "message" {
+		return
+	}
+
+	b.runCommand(ctx, ev)
+}
 
-// SendMessage sends a message to a Slack channel.
-func (b *Bot) SendMessage(message, channel, thread string) {
-    _, _, err := b.Config.Slack.SendMessage(channel, message, slack.MsgOptionTS(thread))
-    if err != nil {
-        b.Config.Log.Err(err).Error("failed to send message")
-    }
+// SendMessage sends a message to a Slack channel. If blocks are
+// passed, the message is rendered as Block Kit and the text becomes
+// its plain-text fallback.
+func (b *Bot) SendMessage(message, channel, thread string, blocks ...slack.Block) {
+	_, _, err := b.Config.Slack.SendMessage(channel, message, append([]slack.MsgOption{slack.MsgOptionTS(thread)}, blockOptions(blocks)...)...)
+	if err != nil {
+		b.Config.Log.Err(err).Error("failed to send message")
+	}
 }
+
 // SendReply sends a reply to a message, either as a new message in the channel or a thread (configurable)
-func (b *Bot) SendReply(reply string, message *slackevents.MessageEvent) {
+func (b *Bot) SendReply(reply string, message *slackevents.MessageEvent, blocks ...slack.Block) {
+	if responseURL, ok := b.commands.Load(message); ok {
+		b.respond(reply, responseURL.(string), b.Config.PublicCommands, blocks...)
+		return
+	}
+
 	switch b.Config.ReplyType {
 	case "ephemeral":
-		b.SendReplyEphemeral(reply, message)
+		b.SendReplyEphemeral(reply, message, blocks...)
 	default:
-		b.SendMessage(reply, message.Channel, b.getReplyThread(message))
+		b.SendMessage(reply, message.Channel, b.getReplyThread(message), blocks...)
 	}
 }
 
 // SendReplyEphemeral sends a reply to a message as an ephemeral message to the user
-func (b *Bot) SendReplyEphemeral(reply string, message *slackevents.MessageEvent) {
-	b.SendMessageEphemeral(message.Channel, message.User, reply, message.ThreadTimeStamp)
+func (b *Bot) SendReplyEphemeral(reply string, message *slackevents.MessageEvent, blocks ...slack.Block) {
+	if responseURL, ok := b.commands.Load(message); ok {
+		b.respond(reply, responseURL.(string), false, blocks...)
+		return
+	}
+
+	b.SendMessageEphemeral(reply, message.Channel, message.User, message.ThreadTimeStamp, blocks...)
 }
 
 // SendMessageEphemeral sends an ephemeral message to a user
-func (b *Bot) SendMessageEphemeral(reply, channel, user, thread string) {
-	b.Config.Slack.PostEphemeral(channel, user, slack.MsgOptionText(reply, false), slack.MsgOptionTS(thread))
+func (b *Bot) SendMessageEphemeral(reply, channel, user, thread string, blocks ...slack.Block) {
+	b.Config.Slack.PostEphemeral(channel, user, append([]slack.MsgOption{slack.MsgOptionText(reply, false), slack.MsgOptionTS(thread)}, blockOptions(blocks)...)...)
 }
 
 func (b *Bot) getReplyThread(message *slackevents.MessageEvent) string {
@@ -272,7 +454,16 @@ func (b *Bot) handleError(err error, message *slackevents.MessageEvent) bool {
 		if b.Config.Debug {
 			text = err.Error()
 		} else {
-			text = "an error has occurred."
+			text = b.locale(message.Channel).Sprintf("an error has occurred.")
+		}
+
+		if tmpl := b.templates().Error; tmpl != nil {
+			reply, err := execute(tmpl, &ErrorReply{Message: text})
+			if err != nil {
+				b.Config.Log.Err(err).Error("could not render the error template")
+			} else {
+				text = reply
+			}
 		}
 
 		b.SendReply(text, message)
@@ -280,135 +471,416 @@ func (b *Bot) handleError(err error, message *slackevents.MessageEvent) bool {
 
 	return true
 }
-func (b *Bot) givePoints(ev *slackevents.MessageEvent) {
-	match := regexps.GiveKarma.FindStringSubmatch(ev.Text)
-	if len(match) == 0 {
+
+// givePoints applies every karma operation in a message and replies
+// with the new totals of all targets. Repeated operations on the same
+// target are ignored, and operations are limited to the giver's
+// remaining budget.
+func (b *Bot) givePoints(ctx context.Context, ev *slackevents.MessageEvent) {
+	operations, reason := b.parseOperations(ev.Text)
+	if len(operations) == 0 {
 		return
 	}
 
-	// forgive me
-	if match[1] != "" {
-		// we matched the first alt expression
-		match = match[:4]
-	} else {
-		// we matched the second alt expression
-		match = append(match[:1], match[4:]...)
+	l := b.locale(ev.Channel)
+
+	// cache the channel's name so that the web UI can filter by it
+	_, err := b.getChannelName(ctx, ev.Channel)
+	if err != nil {
+		b.Config.Log.Err(err).KV("channel", ev.Channel).Error("could not look up channel")
 	}
 
-	from, err := b.getUserNameByID(ev.User)
-	if b.handleError(err, ev) {
+	// resolve every target first so that a failed lookup does not
+	// leave the message partially applied
+	targets := make([]*target, len(operations))
+	for i, op := range operations {
+		targets[i], err = b.parseUser(ctx, op.user)
+		if b.handleError(err, ev) {
+			return
+		}
+	}
+
+	var (
+		from     = ev.User
+		seen     = make(map[string]bool)
+		lines    []string
+		blocks   []slack.Block
+		budgeted bool
+	)
+	for i, op := range operations {
+		to := targets[i]
+		if seen[to.key] {
+			continue
+		}
+		seen[to.key] = true
+
+		if b.isBlacklisted(to.key, to.name) {
+			b.Config.Log.KV("user", to.name).Info("user is blacklisted, ignoring karma command")
+			continue
+		}
+
+		if !b.Config.SelfKarma && from == to.key {
+			lines = append(lines, l.Sprintf("Sorry, you are not allowed to do that."))
+			blocks = append(blocks, textBlock(l.Sprintf("Sorry, you are not allowed to do that.")))
+			continue
+		}
+
+		points := min(len(op.points)-1, b.Config.MaxPoints)
+		if op.points[0] == '-' {
+			points *= -1
+		}
+		if b.Config.Budget > 0 {
+			points, err = b.budgetedPoints(ctx, from, to.key, points)
+			if b.handleError(err, ev) {
+				return
+			}
+
+			budgeted = true
+			if points == 0 {
+				continue
+			}
+		}
+
+		record := &database.Points{
+			From:    from,
+			To:      to.key,
+			Points:  points,
+			Reason:  reason,
+			Kind:    to.kind,
+			Channel: ev.Channel,
+		}
+
+		err = b.Config.DB.InsertPoints(ctx, record)
+		if b.handleError(err, ev) {
+			return
+		}
+
+		pointsMsg, opBlocks, err := b.getUserPointsMessage(ctx, l, from, to.key, reason, points)
+		if b.handleError(err, ev) {
+			return
+		}
+
+		lines = append(lines, pointsMsg)
+		blocks = append(blocks, opBlocks...)
+	}
+
+	if len(lines) > 0 {
+		b.SendReply(strings.Join(lines, "\n"), ev, blocks...)
+	}
+
+	if budgeted {
+		b.sendBudget(ctx, ev)
+	}
+}
+
+func (b *Bot) undoPoints(ctx context.Context, ev *slackevents.MessageEvent) {
+	if b.Config.UndoWindow <= 0 {
+		return
+	}
+
+	l := b.locale(ev.Channel)
+	record, err := b.Config.DB.RevokeLast(ctx, ev.User, time.Now().Add(-b.Config.UndoWindow))
+	if err == database.ErrNoSuchRecord {
+		b.SendReply(l.Sprintf("you do not have any recent karma operations to undo."), ev)
 		return
 	}
-	to, err := b.parseUser(match[1])
 	if b.handleError(err, ev) {
 		return
 	}
-	to = strings.ToLower(to)
 
-	if _, blacklisted := b.Config.UserBlacklist[to]; blacklisted {
-		b.Config.Log.KV("user", to).Info("user is blacklisted, ignoring karma command")
+	text := l.Sprintf("undid %+d", record.Points)
+	user, err := b.Config.DB.GetUser(ctx, record.To, b.scoreFilter())
+	switch {
+	case err == database.ErrNoSuchUser:
+		// that was the user's only karma operation. things
+		// are not slack users and are shown as they are
+		name := record.To
+		if username, err := b.getUserNameByID(ctx, record.To); err == nil {
+			name = username
+		}
+		text = l.Sprintf("%s == 0 (%s)", name, text)
+	case b.handleError(err, ev):
 		return
+	default:
+		text = l.Sprintf("%s == %d (%s)", user.Name, user.Points, text)
 	}
 
-	points := min(len(match[2])-1, b.Config.MaxPoints)
-	if match[2][0] == '-' {
-		points *= -1
+	b.SendReply(text, ev)
+}
+
+func (b *Bot) getThrowback(ctx context.Context, ev *slackevents.MessageEvent) {
+	match := b.regexps.Throwback.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
 	}
-	reason := match[3]
 
-	if !b.Config.SelfKarma && from == to {
-		b.SendReply("Sorry, you are not allowed to do that.", ev)
+	var (
+		user *target
+		err  error
+	)
+	if match[1] != "" {
+		user, err = b.parseUser(ctx, match[1])
+	} else {
+		user, err = b.currentUser(ctx, ev.User)
+	}
+	if b.handleError(err, ev) {
 		return
 	}
 
-	record := &database.Points{
-		From:   from,
-		To:     to,
-		Points: points,
-		Reason: reason,
+	l := b.locale(ev.Channel)
+	throwback, err := b.Config.DB.GetThrowback(ctx, user.key)
+	if err == database.ErrNoSuchUser {
+		b.SendReply(l.Sprintf("could not find any karma operations for %s", user.name), ev)
+		return
 	}
 
-	err = b.Config.DB.InsertPoints(record)
 	if b.handleError(err, ev) {
 		return
 	}
 
-	pointsMsg, err := b.getUserPointsMessage(to, reason, points)
-	if b.handleError(err, ev) {
+	throwback.Reason = l.Reason(throwback.Reason)
+	date := l.Time(throwback.Timestamp)
+	if tmpl := b.templates().Throwback; tmpl != nil {
+		text, err := execute(tmpl, &ThrowbackReply{
+			Name:      munge.Munge(throwback.To),
+			Giver:     munge.Munge(throwback.From),
+			Points:    throwback.Points.Points,
+			Reason:    throwback.Reason,
+			Time:      date,
+			Timestamp: throwback.Timestamp,
+		})
+		if b.handleError(err, ev) {
+			return
+		}
+
+		b.SendReply(text, ev, textBlock(text))
 		return
 	}
 
-	b.SendReply(pointsMsg, ev)
+	reason := ""
+	if throwback.Reason != "" {
+		reason = l.Sprintf(" for %s", throwback.Reason)
+	}
+	text := l.Sprintf("%s received %d points from %s %s%s", munge.Munge(throwback.To), throwback.Points.Points, munge.Munge(throwback.From), date, reason)
+
+	b.SendReply(text, ev, throwbackBlocks(l, throwback)...)
 }
 
-func (b *Bot) getThrowback(ev *slackevents.MessageEvent) {
-	match := regexps.Throwback.FindStringSubmatch(ev.Text)
+func (b *Bot) printHistory(ctx context.Context, ev *slackevents.MessageEvent) {
+	match := b.regexps.History.FindStringSubmatch(ev.Text)
 	if len(match) == 0 {
 		return
 	}
 
 	var (
-		user string
+		user *target
 		err  error
 	)
-	if match[1] != "" {
-		user, err = b.parseUser(match[1])
+	if match[2] != "" {
+		user, err = b.parseUser(ctx, match[2])
+	} else {
+		user, err = b.currentUser(ctx, ev.User)
+	}
+	if b.handleError(err, ev) {
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if match[3] != "" {
+		limit, err = strconv.Atoi(match[3])
 		if b.handleError(err, ev) {
 			return
 		}
-		user = strings.ToLower(user)
+	}
+	limit = min(limit, maxListLimit)
+
+	history, err := b.Config.DB.GetHistory(ctx, user.key, limit, 0)
+	if b.handleError(err, ev) {
+		return
+	}
+
+	l := b.locale(ev.Channel)
+	if len(history) == 0 {
+		b.SendReply(l.Sprintf("could not find any karma operations for %s", user.name), ev)
+		return
+	}
+
+	text := l.Sprintf("*karma history for %s*\n", munge.Munge(user.name))
+	for _, record := range history {
+		text += l.Sprintf("%+d from %s %s", record.Points.Points, munge.Munge(record.From), l.Time(record.Timestamp))
+		if record.Reason != "" {
+			text += l.Sprintf(" for %s", l.Reason(record.Reason))
+		}
+		text += "\n"
+	}
+
+	b.SendReply(text, ev)
+}
+
+func (b *Bot) printReasons(ctx context.Context, ev *slackevents.MessageEvent) {
+	match := b.regexps.Reasons.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	var (
+		user *target
+		err  error
+	)
+	if match[2] != "" {
+		user, err = b.parseUser(ctx, match[2])
 	} else {
-		user, err = b.getUserNameByID(ev.User)
+		user, err = b.currentUser(ctx, ev.User)
+	}
+	if b.handleError(err, ev) {
+		return
+	}
+
+	limit := defaultReasonsLimit
+	if match[3] != "" {
+		limit, err = strconv.Atoi(match[3])
 		if b.handleError(err, ev) {
 			return
 		}
 	}
+	limit = min(limit, maxListLimit)
 
-	throwback, err := b.Config.DB.GetThrowback(user)
-	if err == database.ErrNoSuchUser {
-		b.SendReply(fmt.Sprintf("could not find any karma operations for %s", user), ev)
+	reasons, err := b.Config.DB.GetReasons(ctx, user.key, limit)
+	if b.handleError(err, ev) {
 		return
 	}
 
-	if b.handleError(err, ev) {
+	l := b.locale(ev.Channel)
+	if len(reasons) == 0 {
+		b.SendReply(l.Sprintf("could not find any reasons for %s", user.name), ev)
 		return
 	}
 
-	date := humanize.Time(throwback.Timestamp)
-	if throwback.Reason != "" {
-		throwback.Reason = fmt.Sprintf(" for %s", throwback.Reason)
+	text := l.Sprintf("*top reasons for %s*\n", munge.Munge(user.name))
+	for i, reason := range reasons {
+		text += l.Sprintf("%d. %s: %+d points in %d operations\n", i+1, l.Reason(reason.Reason), reason.Points, reason.Operations)
 	}
-	text := fmt.Sprintf("%s received %d points from %s %s%s", munge.Munge(throwback.To), throwback.Points.Points, munge.Munge(throwback.From), date, throwback.Reason)
 
 	b.SendReply(text, ev)
 }
 
-
-func (b *Bot) queryKarma(ev *slackevents.MessageEvent) {
-	match := regexps.QueryKarma.FindStringSubmatch(ev.Text)
+func (b *Bot) queryKarma(ctx context.Context, ev *slackevents.MessageEvent) {
+	match := b.regexps.QueryKarma.FindStringSubmatch(ev.Text)
 	if len(match) == 0 {
 		return
 	}
 
-	name, err := b.parseUser(match[1])
+	target, err := b.parseUser(ctx, match[1])
 	if b.handleError(err, ev) {
 		return
 	}
-	name = strings.ToLower(name)
 
-	user, err := b.Config.DB.GetUser(name)
+	filter := b.scoreFilter()
+	if match[2] != "" {
+		filter = nil
+	}
+
+	user, err := b.Config.DB.GetUser(ctx, target.key, filter)
 	switch {
 	case err == database.ErrNoSuchUser:
 		// override debug mode
-		b.SendReply(err.Error(), ev)
+		b.SendReply(b.locale(ev.Channel).Sprintf("no such user"), ev)
 	case b.handleError(err, ev):
+	case b.templates().Query != nil:
+		rank, err := b.Config.DB.GetRank(ctx, target.key, filter)
+		if b.handleError(err, ev) {
+			return
+		}
+
+		text, err := execute(b.templates().Query, &QueryReply{Name: user.Name, Points: user.Points, Rank: rank})
+		if b.handleError(err, ev) {
+			return
+		}
+
+		b.SendReply(text, ev)
 	default:
 		b.SendReply(fmt.Sprintf("%s == %d", user.Name, user.Points), ev)
 	}
 }
 
-func (b *Bot) printLeaderboard(ev *slackevents.MessageEvent) {
-	match := regexps.Leaderboard.FindStringSubmatch(ev.Text)
+func (b *Bot) printLeaderboard(ctx context.Context, ev *slackevents.MessageEvent) {
+	match := b.regexps.Leaderboard.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	limit := b.Config.LeaderboardLimit
+	if match[1] != "" {
+		var err error
+		limit, err = strconv.Atoi(match[1])
+		if b.handleError(err, ev) {
+			return
+		}
+	}
+
+	filter, err := parseTimeRange(match[4], time.Now())
+	if b.handleError(err, ev) {
+		return
+	}
+	if filter == nil {
+		filter = &database.Filter{}
+	}
+	if match[5] == "" {
+		filter.HalfLife = b.Config.HalfLife
+	}
+
+	l := b.locale(ev.Channel)
+	title := l.Sprintf("leaderboard")
+	switch match[2] {
+	case "people":
+		filter.Kind = database.KindUser
+		title = l.Sprintf("people")
+	case "things":
+		filter.Kind = database.KindThing
+		title = l.Sprintf("things")
+	}
+
+	var channel string
+	if match[3] != "" {
+		filter.Channel = ev.Channel
+		title = l.Sprintf("%s in <#%s>", title, ev.Channel)
+
+		channel, err = b.getChannelName(ctx, ev.Channel)
+		if b.handleError(err, ev) {
+			return
+		}
+	}
+
+	page := &leaderboardPage{
+		Limit:   limit,
+		Heading: l.Sprintf("*top %d %s*", limit, title),
+		Filter:  filter,
+	}
+	if match[4] != "" {
+		page.Heading = l.Sprintf("*top %d %s %s*", limit, title, match[4])
+	}
+	if tmpl := b.templates().LeaderboardHeader; tmpl != nil {
+		page.Heading, err = execute(tmpl, &LeaderboardHeader{Limit: limit, Title: title, Period: match[4]})
+		if b.handleError(err, ev) {
+			return
+		}
+	}
+
+	// the web UI can only filter by cached channel names
+	if filter.Channel == "" || channel != "" {
+		page.Path = fmt.Sprintf("/leaderboard/%d%s", limit, filterQuery(filter, channel))
+	}
+
+	text, blocks, err := page.render(ctx, b.Config.DB, b.Config.UI, l, b.templates().LeaderboardRow)
+	if b.handleError(err, ev) {
+		return
+	}
+
+	b.SendReply(text, ev, blocks...)
+}
+
+func (b *Bot) printGivers(ctx context.Context, ev *slackevents.MessageEvent) {
+	match := b.regexps.Givers.FindStringSubmatch(ev.Text)
 	if len(match) == 0 {
 		return
 	}
@@ -421,10 +893,12 @@ func (b *Bot) printLeaderboard(ev *slackevents.MessageEvent) {
 			return
 		}
 	}
+	limit = min(limit, maxListLimit)
 
-	text := fmt.Sprintf("*top %d leaderboard*\n", limit)
+	l := b.locale(ev.Channel)
+	text := l.Sprintf("*top %d givers*\n", limit)
 
-	url, err := b.Config.UI.GetURL(fmt.Sprintf("/leaderboard/%d", limit))
+	url, err := b.Config.UI.GetURL(fmt.Sprintf("/givers/%d", limit))
 	if b.handleError(err, ev) {
 		return
 	}
@@ -432,34 +906,83 @@ func (b *Bot) printLeaderboard(ev *slackevents.MessageEvent) {
 		text = fmt.Sprintf("%s%s\n", text, url)
 	}
 
-	leaderboard, err := b.Config.DB.GetLeaderboard(limit)
+	givers, err := b.Config.DB.GetGivers(ctx, limit)
 	if b.handleError(err, ev) {
 		return
 	}
 
-	for i, user := range leaderboard {
-		text += fmt.Sprintf("%d. %s == %d\n", i+1, munge.Munge(user.Name), user.Points)
+	for i, giver := range givers {
+		text += l.Sprintf("%d. %s gave %d points in %d operations\n", i+1, munge.Munge(giver.Name), giver.Points, giver.Operations)
 	}
 
 	b.SendReply(text, ev)
 }
 
-func (b *Bot) getUserNameByID(id string) (string, error) {
+// getUserNameByID looks up a Slack user's username and caches
+// it in the database.
+func (b *Bot) getUserNameByID(ctx context.Context, id string) (string, error) {
 	userInfo, err := b.Config.Slack.GetUserInfo(id)
 	if err != nil {
 		return "", err
 	}
 
+	err = b.Config.DB.SetUserName(ctx, id, userInfo.Name)
+	if err != nil {
+		return "", err
+	}
+
 	return userInfo.Name, nil
 }
 
-func (b *Bot) parseUser(user string) (string, error) {
-	if match := regexps.SlackUser.FindStringSubmatch(user); len(match) > 0 {
-		var err error
-		user, err = b.getUserNameByID(match[1])
+// getChannelName looks up a Slack channel's name and caches it in
+// the database. Direct messages do not have names and are not cached.
+func (b *Bot) getChannelName(ctx context.Context, id string) (string, error) {
+	channelInfo, err := b.Config.Slack.GetConversationInfo(id)
+	if err != nil {
+		return "", err
+	}
+
+	if channelInfo.Name == "" {
+		return "", nil
+	}
+
+	err = b.Config.DB.SetChannelName(ctx, id, channelInfo.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return channelInfo.Name, nil
+}
+
+// A target is the recipient of a karma command.
+type target struct {
+	// key is what the target's karma is stored under: the Slack
+	// user ID for users and the lowercased text for anything else.
+	key string
+
+	// name can be shown in replies.
+	name string
+
+	// kind is either database.KindUser or database.KindThing.
+	kind string
+}
+
+// parseUser resolves the target of a karma command. Slack mentions
+// and the usernames of Slack users that karmabot has seen before are
+// users and anything else is a thing.
+func (b *Bot) parseUser(ctx context.Context, user string) (*target, error) {
+	if match := b.regexps.SlackUser.FindStringSubmatch(user); len(match) > 0 {
+		name, err := b.getUserNameByID(ctx, match[1])
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+
+		// aliases are configured by username
+		if alias, ok := b.Config.Aliases[name]; ok {
+			return b.lookupUser(ctx, alias)
+		}
+
+		return &target{key: match[1], name: name, kind: database.KindUser}, nil
 	}
 
 	// check if it is aliased
@@ -467,16 +990,95 @@ func (b *Bot) parseUser(user string) (string, error) {
 		user = alias
 	}
 
-	return user, nil
+	return b.lookupUser(ctx, user)
+}
+
+// lookupUser returns the Slack user with the passed username if
+// karmabot has seen them before, or a thing with the lowercased
+// name otherwise.
+func (b *Bot) lookupUser(ctx context.Context, name string) (*target, error) {
+	id, err := b.Config.DB.GetUserID(ctx, name)
+	switch err {
+	case nil:
+		return &target{key: id, name: name, kind: database.KindUser}, nil
+	case database.ErrNoSuchUser:
+		name = strings.ToLower(name)
+		return &target{key: name, name: name, kind: database.KindThing}, nil
+	default:
+		return nil, err
+	}
 }
 
-func (b *Bot) getUserPointsMessage(name, reason string, points int) (string, error) {
-	user, err := b.Config.DB.GetUser(name)
+// currentUser returns the Slack user who sent a command.
+func (b *Bot) currentUser(ctx context.Context, id string) (*target, error) {
+	name, err := b.getUserNameByID(ctx, id)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	text := fmt.Sprintf("%s == %d (", name, user.Points)
+	return &target{key: id, name: name, kind: database.KindUser}, nil
+}
+
+// isBlacklisted checks whether karma operations on a user are ignored.
+// Users can be blacklisted by either their ID or their name.
+func (b *Bot) isBlacklisted(id, name string) bool {
+	return b.Config.UserBlacklist.Contains(id) || b.Config.UserBlacklist.Contains(strings.ToLower(name))
+}
+
+// scoreFilter returns the filter that users' scores are reported
+// with, which applies the configured decay if any.
+func (b *Bot) scoreFilter() *database.Filter {
+	if b.Config.HalfLife <= 0 {
+		return nil
+	}
+
+	return &database.Filter{HalfLife: b.Config.HalfLife}
+}
+
+// getUserPointsMessage renders a user's points after a karma
+// operation by the user with the ID from.
+func (b *Bot) getUserPointsMessage(ctx context.Context, l *locale, from, id, reason string, points int) (string, []slack.Block, error) {
+	reason = l.Reason(reason)
+	user, err := b.Config.DB.GetUser(ctx, id, b.scoreFilter())
+	if err != nil {
+		return "", nil, err
+	}
+
+	if tmpl := b.templates().Points; tmpl != nil {
+		rank, err := b.Config.DB.GetRank(ctx, id, b.scoreFilter())
+		if err != nil {
+			return "", nil, err
+		}
+
+		giver, err := b.currentUser(ctx, from)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// Previous is derived from the total, so it would be off by
+		// the decay of older points if the total were decayed
+		raw, err := b.Config.DB.GetUser(ctx, id, nil)
+		if err != nil {
+			return "", nil, err
+		}
+
+		text, err := execute(tmpl, &PointsReply{
+			Name:     user.Name,
+			Points:   raw.Points,
+			Previous: raw.Points - points,
+			Delta:    points,
+			Rank:     rank,
+			Giver:    giver.name,
+			Reason:   reason,
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		return text, []slack.Block{textBlock(text)}, nil
+	}
+
+	text := fmt.Sprintf("%s == %d (", user.Name, user.Points)
 
 	if points > 0 {
 		text += "+"
@@ -484,14 +1086,14 @@ func (b *Bot) getUserPointsMessage(name, reason string, points int) (string, err
 	text = fmt.Sprintf("%s%d", text, points)
 
 	if reason != "" {
-		text += fmt.Sprintf(" for %s", reason)
+		text += l.Sprintf(" for %s", reason)
 	}
 	text += ")"
 
-	return text, nil
+	return text, pointsBlocks(l, user.Name, user.Points, points, reason), nil
 }
 
-func (b *Bot) handleReactionAddedEvent(ev *slackevents.ReactionAddedEvent) {
+func (b *Bot) handleReactionAddedEvent(ctx context.Context, ev *slackevents.ReactionAddedEvent) {
 	if !b.Config.Reactji.Enabled {
 		return
 	}
@@ -509,12 +1111,32 @@ func (b *Bot) handleReactionAddedEvent(ev *slackevents.ReactionAddedEvent) {
 		return
 	}
 
+	// removing reactjis is not limited by the budget so
+	// that users can always take back their reactjis, and
+	// it gives the points back
+	if b.Config.Budget > 0 {
+		allowed, err := b.budgetedPoints(ctx, ev.User, ev.ItemUser, points)
+		if b.handleError(err, nil) {
+			return
+		}
+
+		if allowed == 0 {
+			remaining, err := b.remainingBudget(ctx, ev.User)
+			if b.handleError(err, nil) {
+				return
+			}
+
+			b.SendMessageEphemeral(b.budgetMessage(b.locale(ev.Item.Channel), remaining), ev.Item.Channel, ev.User, "")
+			return
+		}
+	}
+
 	reason = fmt.Sprintf("added a :%s: reactji", ev.Reaction)
 	fmt.Printf("points %d, reason %s\n", points, reason)
-	b.handleReactionEvent(ev, reason, points)
+	b.handleReactionEvent(ctx, ev, reason, points)
 }
 
-func (b *Bot) handleReactionRemovedEvent(ev *slackevents.ReactionRemovedEvent) {
+func (b *Bot) handleReactionRemovedEvent(ctx context.Context, ev *slackevents.ReactionRemovedEvent) {
 	if !b.Config.Reactji.Enabled {
 		return
 	}
@@ -533,44 +1155,59 @@ func (b *Bot) handleReactionRemovedEvent(ev *slackevents.ReactionRemovedEvent) {
 	}
 
 	reason = fmt.Sprintf("removed a :%s: reactji", ev.Reaction)
-	b.handleReactionEvent((*slackevents.ReactionAddedEvent)(ev), reason, points)
+	b.handleReactionEvent(ctx, (*slackevents.ReactionAddedEvent)(ev), reason, points)
 }
 
 // at this point there is no difference between ReactionAddedEvent and ReactionRemovedEvent
-func (b *Bot) handleReactionEvent(ev *slackevents.ReactionAddedEvent, reason string, points int) {
+func (b *Bot) handleReactionEvent(ctx context.Context, ev *slackevents.ReactionAddedEvent, reason string, points int) {
 	// look up usernames
-	from, err := b.getUserNameByID(ev.User)
+	from, err := b.getUserNameByID(ctx, ev.User)
 	if b.handleError(err, nil) {
 		return
 	}
-	to, err := b.getUserNameByID(ev.ItemUser)
+	_, err = b.getUserNameByID(ctx, ev.ItemUser)
 	if b.handleError(err, nil) {
 		return
 	}
+	_, err = b.getChannelName(ctx, ev.Item.Channel)
+	if err != nil {
+		b.Config.Log.Err(err).KV("channel", ev.Item.Channel).Error("could not look up channel")
+	}
 
 	// add the actor's username to the reason
 	reason = fmt.Sprintf("%s %s", from, reason)
 
-	from, to = strings.ToLower(from), strings.ToLower(to)
-
 	// insert points
 	record := &database.Points{
-		From:   from,
-		To:     to,
-		Points: points,
-		Reason: reason,
+		From:    ev.User,
+		To:      ev.ItemUser,
+		Points:  points,
+		Reason:  reason,
+		Kind:    database.KindUser,
+		Channel: ev.Item.Channel,
 	}
 
-	err = b.Config.DB.InsertPoints(record)
+	err = b.Config.DB.InsertPoints(ctx, record)
 	if b.handleError(err, nil) {
 		return
 	}
 
-	pointsMsg, err := b.getUserPointsMessage(to, reason, points)
+	l := b.locale(ev.Item.Channel)
+	pointsMsg, blocks, err := b.getUserPointsMessage(ctx, l, ev.User, ev.ItemUser, reason, points)
 	if b.handleError(err, nil) {
 		return
 	}
 
+	if b.Config.Budget > 0 {
+		remaining, err := b.remainingBudget(ctx, ev.User)
+		if b.handleError(err, nil) {
+			return
+		}
+
+		pointsMsg += "\n" + b.budgetMessage(l, remaining)
+		blocks = append(blocks, slack.NewContextBlock("", markdown(b.budgetMessage(l, remaining))))
+	}
+
 	// reply as ephemeral message
-	b.SendMessageEphemeral(pointsMsg, ev.Item.Channel, ev.User, "")
-}
\ No newline at end of file
+	b.SendMessageEphemeral(pointsMsg, ev.Item.Channel, ev.User, "", blocks...)
+}
@@ -1,40 +1,54 @@
 package karmabot
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aybabtme/log"
 	"github.com/kamaln7/karmabot/database"
-	"github.com/nlopes/slack"
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
 )
 
 func TestNew(t *testing.T) {
 	cfg := &Config{}
 
-	b := New(cfg)
+	b := NewBot(cfg)
 	if b == nil {
-		t.Fatalf("New(cfg) returned nil; wanted *Bot")
+		t.Fatalf("NewBot(cfg) returned nil; wanted *Bot")
 	}
 
 	if b.Config != cfg {
-		t.Errorf("New(cfg): returned Bot with incorrect config")
+		t.Errorf("NewBot(cfg): returned Bot with incorrect config")
 	}
 }
 
-func newBot(cfg *Config) (*Bot, *TestChatService, *TestDatabase) {
+func newBot(cfg *Config) (*Bot, *TestChatService, *testDatabase) {
+	return newBotAt(cfg, time.Now())
+}
+
+// newBotAt returns a bot like newBot does, with the karma
+// operation that its database starts out with performed at
+// timestamp.
+func newBotAt(cfg *Config, timestamp time.Time) (*Bot, *TestChatService, *testDatabase) {
 	cs := &TestChatService{
-		IncomingEvents: make(chan slack.RTMEvent),
+		IncomingEvents: make(chan socketmode.Event),
 	}
-	db := &TestDatabase{}
-	db.InsertPoints(&database.Points{
+	db := newTestDatabase()
+	db.insertAt(&database.Points{
 		From:   "point_giver",
 		To:     "onehundred_points",
 		Points: 100,
 		Reason: "for being a swell guy",
-	})
+	}, timestamp)
 	cfg.Slack = cs
 	cfg.DB = db
-	return New(cfg), cs, db
+	return NewBot(cfg), cs, db
 }
 
 func TestListen(t *testing.T) {
@@ -43,7 +57,7 @@ func TestListen(t *testing.T) {
 	hasStarted := make(chan int)
 	go func() {
 		close(hasStarted)
-		b.Listen()
+		b.Listen(context.Background())
 		hasExited = true
 	}()
 	<-hasStarted
@@ -60,20 +74,70 @@ func TestListen(t *testing.T) {
 	// TODO: To properly test Listen, it needs to be decoupled further from what it actually does.
 }
 
+func TestListenForeignTeam(t *testing.T) {
+	b, cs, db := newBot(&Config{Team: "TA", MaxPoints: 6, Log: log.KV("test", t.Name())})
+
+	done := make(chan struct{})
+	go func() {
+		b.Listen(context.Background())
+		close(done)
+	}()
+
+	for i, ev := range []socketmode.Event{
+		{
+			Type: socketmode.EventTypeEventsAPI,
+			Data: slackevents.EventsAPIEvent{
+				Type:   slackevents.CallbackEvent,
+				TeamID: "TB",
+				InnerEvent: slackevents.EventsAPIInnerEvent{
+					Type: "message",
+					Data: &slackevents.MessageEvent{Type: "message", Text: "bob++", Channel: "channel", User: "U9876"},
+				},
+			},
+		},
+		{
+			Type: socketmode.EventTypeSlashCommand,
+			Data: slack.SlashCommand{TeamID: "TB", Command: "/karma", Text: "top", ChannelID: "channel", UserID: "U9876"},
+		},
+		{
+			Type: socketmode.EventTypeInteractive,
+			Data: slack.InteractionCallback{Type: slack.InteractionTypeBlockActions, Team: slack.Team{ID: "TB"}},
+		},
+	} {
+		ev.Request = &socketmode.Request{EnvelopeID: fmt.Sprint(i)}
+		cs.IncomingEvents <- ev
+	}
+	close(cs.IncomingEvents)
+	<-done
+
+	// give handlers that should not have been started a chance to run
+	time.Sleep(10 * time.Millisecond)
+
+	if len(cs.Acked) != 3 {
+		t.Errorf("acked %v; want all 3 requests", cs.Acked)
+	}
+	if len(cs.SentMessages) != 0 {
+		t.Errorf("sent messages %+v; want none", cs.SentMessages)
+	}
+	if len(db.records) != 1 {
+		t.Errorf("stored %d records; want none besides the initial one", len(db.records)-1)
+	}
+}
+
 func TestHandleSlackEvent(t *testing.T) {
 	tt := []struct {
 		Name                 string
 		ReacjiDisabled       bool
-		ReactionAddedEvent   *slack.ReactionAddedEvent
-		ReactionRemovedEvent *slack.ReactionRemovedEvent
-		MessageEvent         *slack.MessageEvent
+		ReactionAddedEvent   *slackevents.ReactionAddedEvent
+		ReactionRemovedEvent *slackevents.ReactionRemovedEvent
+		MessageEvent         *slackevents.MessageEvent
 		ExpectMessage        string
 		ShouldHavePoints     int
 	}{
 		{
 			Name:           "+1 added with reacji disabled",
 			ReacjiDisabled: true,
-			ReactionAddedEvent: &slack.ReactionAddedEvent{
+			ReactionAddedEvent: &slackevents.ReactionAddedEvent{
 				Type:     "reaction_added",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -83,7 +147,7 @@ func TestHandleSlackEvent(t *testing.T) {
 		},
 		{
 			Name: "+1 added with reacji enabled",
-			ReactionAddedEvent: &slack.ReactionAddedEvent{
+			ReactionAddedEvent: &slackevents.ReactionAddedEvent{
 				Type:     "reaction_added",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -94,7 +158,7 @@ func TestHandleSlackEvent(t *testing.T) {
 		},
 		{
 			Name: "-1 added with reacji enabled",
-			ReactionAddedEvent: &slack.ReactionAddedEvent{
+			ReactionAddedEvent: &slackevents.ReactionAddedEvent{
 				Type:     "reaction_added",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -105,7 +169,7 @@ func TestHandleSlackEvent(t *testing.T) {
 		},
 		{
 			Name: "cat added with reacji enabled",
-			ReactionAddedEvent: &slack.ReactionAddedEvent{
+			ReactionAddedEvent: &slackevents.ReactionAddedEvent{
 				Type:     "reaction_added",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -117,7 +181,7 @@ func TestHandleSlackEvent(t *testing.T) {
 		{
 			Name:           "+1 removed with reacji disabled",
 			ReacjiDisabled: true,
-			ReactionRemovedEvent: &slack.ReactionRemovedEvent{
+			ReactionRemovedEvent: &slackevents.ReactionRemovedEvent{
 				Type:     "reaction_removed",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -127,7 +191,7 @@ func TestHandleSlackEvent(t *testing.T) {
 		},
 		{
 			Name: "+1 removed with reacji enabled",
-			ReactionRemovedEvent: &slack.ReactionRemovedEvent{
+			ReactionRemovedEvent: &slackevents.ReactionRemovedEvent{
 				Type:     "reaction_removed",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -138,7 +202,7 @@ func TestHandleSlackEvent(t *testing.T) {
 		},
 		{
 			Name: "-1 removed with reacji enabled",
-			ReactionRemovedEvent: &slack.ReactionRemovedEvent{
+			ReactionRemovedEvent: &slackevents.ReactionRemovedEvent{
 				Type:     "reaction_removed",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -149,7 +213,7 @@ func TestHandleSlackEvent(t *testing.T) {
 		},
 		{
 			Name: "cat removed with reacji enabled",
-			ReactionRemovedEvent: &slack.ReactionRemovedEvent{
+			ReactionRemovedEvent: &slackevents.ReactionRemovedEvent{
 				Type:     "reaction_removed",
 				User:     "user",
 				ItemUser: "onehundred_points",
@@ -159,13 +223,11 @@ func TestHandleSlackEvent(t *testing.T) {
 		},
 		{
 			Name: "should tell user about their sick karma events from the past",
-			MessageEvent: &slack.MessageEvent{
-				Msg: slack.Msg{
-					Type:    "message",
-					Text:    "karmabot throwback",
-					Channel: "user",
-					User:    "onehundred_points",
-				},
+			MessageEvent: &slackevents.MessageEvent{
+				Type:    "message",
+				Text:    "karmabot throwback",
+				Channel: "user",
+				User:    "onehundred_points",
 			},
 			ExpectMessage:    "önehundred_points received 100 points from ρoint_giver now for for being a swell guy",
 			ShouldHavePoints: 100,
@@ -186,13 +248,13 @@ func TestHandleSlackEvent(t *testing.T) {
 		})
 
 		if tc.ReactionAddedEvent != nil {
-			b.handleReactionAddedEvent(tc.ReactionAddedEvent)
+			b.handleReactionAddedEvent(context.Background(), tc.ReactionAddedEvent)
 		}
 		if tc.ReactionRemovedEvent != nil {
-			b.handleReactionRemovedEvent(tc.ReactionRemovedEvent)
+			b.handleReactionRemovedEvent(context.Background(), tc.ReactionRemovedEvent)
 		}
 		if tc.MessageEvent != nil {
-			b.handleMessageEvent(tc.MessageEvent)
+			b.handleMessageEvent(context.Background(), tc.MessageEvent)
 		}
 
 		if len(cs.SentMessages) != 0 && tc.ExpectMessage == "" {
@@ -211,7 +273,7 @@ func TestHandleSlackEvent(t *testing.T) {
 			}
 		}
 
-		u, err := db.GetUser("onehundred_points")
+		u, err := db.GetUser(context.Background(), "onehundred_points", nil)
 		if err != nil {
 			t.Fatalf("%s: db.GetUser: %v", tc.Name, err)
 		}
@@ -221,3 +283,345 @@ func TestHandleSlackEvent(t *testing.T) {
 		}
 	}
 }
+
+func TestGivePointsStoresUserIDs(t *testing.T) {
+	b, _, db := newBot(&Config{MaxPoints: 6})
+
+	for _, text := range []string{"<@U1234>++", "u1234++", "coffee++"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	u, err := db.GetUser(context.Background(), "U1234", nil)
+	if err != nil {
+		t.Fatalf("db.GetUser: %v", err)
+	}
+	if u.Points != 2 {
+		t.Errorf("user %v has %v points; want %v", "U1234", u.Points, 2)
+	}
+
+	if _, err := db.GetUser(context.Background(), "coffee", nil); err != nil {
+		t.Errorf("db.GetUser(context.Background(), %q): %v", "coffee", err)
+	}
+
+	kinds := []string{database.KindUser, database.KindUser, database.KindThing}
+	for i, r := range db.records[1:] {
+		if r.From != "U9876" {
+			t.Errorf("record %+v: stored giver %q; want %q", r, r.From, "U9876")
+		}
+		if r.Kind != kinds[i] {
+			t.Errorf("record %+v: stored kind %q; want %q", r, r.Kind, kinds[i])
+		}
+	}
+}
+
+func TestPrintLeaderboardKind(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10, MaxPoints: 6})
+
+	for _, text := range []string{"<@U1234>+++", "coffee++", "karma top things", "karma top 5 people this week"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	if len(db.records) != 3 {
+		t.Fatalf("stored %d records; want %d", len(db.records), 3)
+	}
+
+	want := []string{
+		"*top 10 things*\n1. ċoffee == 1\n",
+		"*top 5 people this week*\n1. Ů1234 == 2\n",
+	}
+	if len(cs.SentMessages) != 4 {
+		t.Fatalf("sent messages %+v; want 4 messages", cs.SentMessages)
+	}
+	for i, msg := range cs.SentMessages[2:] {
+		if msg.Text != want[i] {
+			t.Errorf("sent message %q; want %q", msg.Text, want[i])
+		}
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	b, cs, db := newBot(&Config{})
+	db.InsertPoints(context.Background(), &database.Points{
+		From:   "someone",
+		To:     "onehundred_points",
+		Points: -2,
+	})
+	db.InsertPoints(context.Background(), &database.Points{
+		From:   "someone_else",
+		To:     "onehundred_points",
+		Points: 1,
+		Reason: "good job",
+	})
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma history onehundred_points 2",
+		Channel: "channel",
+	})
+
+	want := "*karma history for önehundred_points*\n+1 from šomeone_else now for good job\n-2 from šomeone now\n"
+	if len(cs.SentMessages) != 1 || cs.SentMessages[0].Text != want {
+		t.Errorf("sent messages %+v; want %q", cs.SentMessages, want)
+	}
+}
+
+func TestUndoPoints(t *testing.T) {
+	b, cs, db := newBot(&Config{MaxPoints: 6, UndoWindow: time.Minute})
+
+	for _, text := range []string{"onehundred_points+++++", "karma undo", "karma undo"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	want := []string{
+		"onehundred_points == 104 (+4)",
+		"onehundred_points == 100 (undid +4)",
+		"you do not have any recent karma operations to undo.",
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent %d messages; want %d", len(cs.SentMessages), len(want))
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Text != want[i] {
+			t.Errorf("sent message %q; want %q", msg.Text, want[i])
+		}
+	}
+
+	u, err := db.GetUser(context.Background(), "onehundred_points", nil)
+	if err != nil {
+		t.Fatalf("db.GetUser: %v", err)
+	}
+	if u.Points != 100 {
+		t.Errorf("user has %v points; want %v", u.Points, 100)
+	}
+}
+
+func TestPrintGivers(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10})
+	db.InsertPoints(context.Background(), &database.Points{From: "someone", To: "onehundred_points", Points: 2})
+	db.InsertPoints(context.Background(), &database.Points{From: "someone", To: "onehundred_points", Points: -5})
+	db.InsertPoints(context.Background(), &database.Points{From: "someone", To: "else", Points: 1})
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma givers 5",
+		Channel: "channel",
+	})
+
+	want := "*top 5 givers*\n1. ρoint_giver gave 100 points in 1 operations\n2. šomeone gave 3 points in 2 operations\n"
+	if len(cs.SentMessages) != 1 || cs.SentMessages[0].Text != want {
+		t.Errorf("sent messages %+v; want %q", cs.SentMessages, want)
+	}
+}
+
+func TestGiveMultiplePoints(t *testing.T) {
+	b, cs, db := newBot(&Config{MaxPoints: 6})
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "onehundred_points++ bob++ carol-- bob+++ for the launch",
+		Channel: "channel",
+		User:    "U9876",
+	})
+
+	want := "onehundred_points == 101 (+1 for the launch)\nbob == 1 (+1 for the launch)\ncarol == -1 (-1 for the launch)"
+	if len(cs.SentMessages) != 1 || cs.SentMessages[0].Text != want {
+		t.Errorf("sent messages %+v; want %q", cs.SentMessages, want)
+	}
+
+	if len(db.records) != 4 {
+		t.Errorf("stored %d records; want %d", len(db.records), 4)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	b, cs, db := newBotAt(&Config{UI: blankui.New(), LeaderboardLimit: 10, HalfLife: 24 * time.Hour}, time.Now().Add(-48*time.Hour))
+	db.InsertPoints(context.Background(), &database.Points{From: "someone", To: "bob", Points: 30})
+
+	for _, text := range []string{"karma top", "karma top raw", "onehundred_points==", "onehundred_points== raw"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+		})
+	}
+
+	want := []string{
+		"*top 10 leaderboard*\n1. Бob == 30\n2. önehundred_points == 25\n",
+		"*top 10 leaderboard*\n1. önehundred_points == 100\n2. Бob == 30\n",
+		"onehundred_points == 25",
+		"onehundred_points == 100",
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent messages %+v; want %d messages", cs.SentMessages, len(want))
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Text != want[i] {
+			t.Errorf("sent message %q; want %q", msg.Text, want[i])
+		}
+	}
+}
+
+func TestPrintLeaderboardHere(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10, MaxPoints: 6})
+
+	for _, ev := range []struct{ channel, text string }{
+		{"C1", "bob++"},
+		{"C2", "carol+++"},
+		{"C1", "karma top here"},
+	} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    ev.text,
+			Channel: ev.channel,
+			User:    "U9876",
+		})
+	}
+
+	if db.records[1].Channel != "C1" {
+		t.Errorf("stored record in channel %q; want %q", db.records[1].Channel, "C1")
+	}
+
+	if id, err := db.GetChannelID(context.Background(), "C2"); err != nil || id != "C2" {
+		t.Errorf("db.GetChannelID(context.Background(), %q) = %q, %v; want %q", "C2", id, err, "C2")
+	}
+
+	want := "*top 10 leaderboard in <#C1>*\n1. Бob == 1\n"
+	if len(cs.SentMessages) != 3 || cs.SentMessages[2].Text != want {
+		t.Errorf("sent messages %+v; want %q last", cs.SentMessages, want)
+	}
+}
+
+func TestPrintHistoryLimit(t *testing.T) {
+	b, cs, db := newBot(&Config{})
+	for i := 0; i < maxListLimit+10; i++ {
+		db.InsertPoints(context.Background(), &database.Points{From: "someone", To: "bob", Points: 1})
+	}
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma history bob 100000",
+		Channel: "channel",
+	})
+
+	if len(cs.SentMessages) != 1 {
+		t.Fatalf("sent messages %+v; want 1 message", cs.SentMessages)
+	}
+	if lines := strings.Count(cs.SentMessages[0].Text, "\n"); lines != maxListLimit+1 {
+		t.Errorf("sent %d lines; want a heading and %d operations", lines, maxListLimit)
+	}
+}
+
+func TestPrintReasons(t *testing.T) {
+	b, cs, _ := newBot(&Config{MaxPoints: 6})
+
+	for _, text := range []string{
+		"alice++ for the launch",
+		"alice++ for The  Launch!",
+		"alice-- for bugs",
+		"alice++ for bugs",
+		"alice++",
+		"karma reasons alice",
+		"karma reasons alice 1",
+		"karma reasons bob",
+	} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+
+	want := []string{
+		"*top reasons for älice*\n1. the launch: +2 points in 2 operations\n2. bugs: +0 points in 2 operations\n",
+		"*top reasons for älice*\n1. the launch: +2 points in 2 operations\n",
+		"could not find any reasons for bob",
+	}
+	if len(cs.SentMessages) != 5+len(want) {
+		t.Fatalf("sent messages %+v; want %d messages", cs.SentMessages, 5+len(want))
+	}
+	for i, msg := range cs.SentMessages[5:] {
+		if msg.Text != want[i] {
+			t.Errorf("sent message %q; want %q", msg.Text, want[i])
+		}
+	}
+}
+
+func TestPrintReasonsLimit(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10})
+	for i := 0; i < maxListLimit+10; i++ {
+		db.InsertPoints(context.Background(), &database.Points{From: "someone", To: "bob", Points: 1, Reason: fmt.Sprintf("reason %d", i)})
+	}
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma reasons bob 100000",
+		Channel: "channel",
+	})
+
+	if len(cs.SentMessages) != 1 {
+		t.Fatalf("sent messages %+v; want 1 message", cs.SentMessages)
+	}
+	if lines := strings.Count(cs.SentMessages[0].Text, "\n"); lines != maxListLimit+1 {
+		t.Errorf("sent %d lines; want a heading and %d reasons", lines, maxListLimit)
+	}
+}
+
+func TestPrintGiversLimit(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10})
+	for i := 0; i < maxListLimit+10; i++ {
+		db.InsertPoints(context.Background(), &database.Points{From: fmt.Sprintf("giver%d", i), To: "bob", Points: 1})
+	}
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma givers 100000",
+		Channel: "channel",
+	})
+
+	if len(cs.SentMessages) != 1 {
+		t.Fatalf("sent messages %+v; want 1 message", cs.SentMessages)
+	}
+	if want := fmt.Sprintf("*top %d givers*\n", maxListLimit); !strings.HasPrefix(cs.SentMessages[0].Text, want) {
+		t.Errorf("sent message %q; want it to start with %q", cs.SentMessages[0].Text, want)
+	}
+	if lines := strings.Count(cs.SentMessages[0].Text, "\n"); lines != maxListLimit+1 {
+		t.Errorf("sent %d lines; want a heading and %d givers", lines, maxListLimit)
+	}
+}
+
+func TestGiveMultiplePointsLookupError(t *testing.T) {
+	b, cs, db := newBot(&Config{MaxPoints: 6, Log: log.KV("test", t.Name())})
+	cs.MissingUsers = StringList{"U404": {}}
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "bob++ <@U404>++ for the launch",
+		Channel: "channel",
+		User:    "U9876",
+	})
+
+	if len(db.records) != 1 {
+		t.Errorf("stored %d records; want none besides the initial one", len(db.records)-1)
+	}
+
+	want := "an error has occurred."
+	if len(cs.SentMessages) != 1 || cs.SentMessages[0].Text != want {
+		t.Errorf("sent messages %+v; want %q", cs.SentMessages, want)
+	}
+}
@@ -7,3 +7,11 @@ func min(a, b int) int {
 
 	return b
 }
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+
+	return a
+}
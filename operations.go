@@ -0,0 +1,62 @@
+package karmabot
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An operation is a single karma operation in a message.
+type operation struct {
+	// user is the target of the operation as it was typed.
+	user string
+
+	// points is the run of pluses or minuses.
+	points string
+}
+
+// parseOperations returns every karma operation in a message along
+// with the reason, which is shared by all of them. The reason is the
+// text following the last operation. Operations that are followed by
+// "for" end the list so that the reason can mention things such as
+// c++ without giving them karma.
+func (b *Bot) parseOperations(text string) ([]*operation, string) {
+	var (
+		operations []*operation
+		end        int
+	)
+	for _, m := range b.regexps.Operation.FindAllStringSubmatchIndex(text, -1) {
+		// operations must be followed by whitespace or
+		// the end of the message
+		if r, _ := utf8.DecodeRuneInString(text[m[1]:]); m[1] < len(text) && !unicode.IsSpace(r) {
+			continue
+		}
+
+		// the regex has one pair of groups per alternative
+		user, points := m[2:4], m[4:6]
+		if user[0] < 0 {
+			user, points = m[6:8], m[8:10]
+		}
+
+		operations = append(operations, &operation{
+			user:   text[user[0]:user[1]],
+			points: text[points[0]:points[1]],
+		})
+		end = m[1]
+
+		if strings.HasPrefix(text[end:], " for ") {
+			break
+		}
+	}
+
+	if len(operations) == 0 {
+		return nil, ""
+	}
+
+	reason := strings.TrimSpace(text[end:])
+	if strings.HasPrefix(reason, "for ") {
+		reason = strings.TrimSpace(strings.TrimPrefix(reason, "for "))
+	}
+
+	return operations, reason
+}
@@ -0,0 +1,70 @@
+package karmabot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOperations(t *testing.T) {
+	tt := []struct {
+		Text       string
+		Operations []*operation
+		Reason     string
+	}{
+		{
+			Text:       "alice++",
+			Operations: []*operation{{user: "alice", points: "++"}},
+		},
+		{
+			Text:       "user: ---- autocomplete test",
+			Operations: []*operation{{user: "user", points: "----"}},
+			Reason:     "autocomplete test",
+		},
+		{
+			Text: "alice++ bob++ carol-- for the launch",
+			Operations: []*operation{
+				{user: "alice", points: "++"},
+				{user: "bob", points: "++"},
+				{user: "carol", points: "--"},
+			},
+			Reason: "the launch",
+		},
+		{
+			Text: "thanks <@U1234>+++ and @bob: ++ great job",
+			Operations: []*operation{
+				{user: "<@U1234>", points: "+++"},
+				{user: "bob", points: "++"},
+			},
+			Reason: "great job",
+		},
+		{
+			Text:       "alice++ for fixing c++ builds",
+			Operations: []*operation{{user: "alice", points: "++"}},
+			Reason:     "fixing c++ builds",
+		},
+		{
+			Text:       "alice++, bob++",
+			Operations: []*operation{{user: "bob", points: "++"}},
+		},
+		{
+			Text: "middle of the sentence ++",
+		},
+	}
+
+	b := NewBot(&Config{})
+	for _, tc := range tt {
+		operations, reason := b.parseOperations(tc.Text)
+		if !reflect.DeepEqual(operations, tc.Operations) {
+			t.Errorf("parseOperations(%q) operations:", tc.Text)
+			for _, op := range operations {
+				t.Errorf("  got %+v", *op)
+			}
+			for _, op := range tc.Operations {
+				t.Errorf("  want %+v", *op)
+			}
+		}
+		if reason != tc.Reason {
+			t.Errorf("parseOperations(%q) reason = %q; want %q", tc.Text, reason, tc.Reason)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package karmabot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/munge"
+	"github.com/kamaln7/karmabot/ui"
+	"github.com/slack-go/slack"
+)
+
+// leaderboardPageSize is the amount of users on a single page of
+// a leaderboard message.
+const leaderboardPageSize = 10
+
+// The IDs of the leaderboard's pagination buttons.
+const (
+	actionPreviousPage = "leaderboard_previous"
+	actionNextPage     = "leaderboard_next"
+)
+
+// A leaderboardPage is a page of a leaderboard message. The
+// pagination buttons carry the page that they lead to, encoded as
+// JSON, so that no state has to be kept between clicks. Path is the
+// leaderboard's path in the web UI, if any. Its URL is generated
+// whenever a page is rendered because it carries a token that
+// expires.
+type leaderboardPage struct {
+	Limit   int              `json:"limit"`
+	Page    int              `json:"page"`
+	Heading string           `json:"heading"`
+	Path    string           `json:"path,omitempty"`
+	Filter  *database.Filter `json:"filter,omitempty"`
+}
+
+// render returns a page of the leaderboard as text and blocks. The
+// blocks end with buttons that lead to the surrounding pages. Rows
+// are rendered with row unless it is nil.
+func (p *leaderboardPage) render(ctx context.Context, db Database, provider ui.Provider, l *locale, row *template.Template) (string, []slack.Block, error) {
+	var (
+		offset = p.Page * leaderboardPageSize
+		end    = min(offset+leaderboardPageSize, p.Limit)
+	)
+
+	// fetch one more user to find out whether there is a next page
+	leaderboard, err := db.GetLeaderboard(ctx, min(end+1, p.Limit), p.Filter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	hasNext := len(leaderboard) > end
+	leaderboard = leaderboard[min(offset, len(leaderboard)):min(end, len(leaderboard))]
+
+	var url string
+	if p.Path != "" {
+		url, err = provider.GetURL(p.Path)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	text := p.Heading + "\n"
+	if url != "" {
+		text = fmt.Sprintf("%s%s\n", text, url)
+	}
+
+	var blocks []slack.Block
+	if row != nil {
+		var rows string
+		for i, user := range leaderboard {
+			line, err := execute(row, &LeaderboardRow{Rank: offset + i + 1, Name: munge.Munge(user.Name), Points: user.Points})
+			if err != nil {
+				return "", nil, err
+			}
+			rows += line + "\n"
+		}
+
+		text += rows
+		blocks = leaderboardBlocks(l, p.Heading, url, nil, offset)
+		if rows != "" {
+			blocks = append(blocks, textBlock(rows))
+		}
+	} else {
+		for i, user := range leaderboard {
+			text += fmt.Sprintf("%d. %s == %d\n", offset+i+1, munge.Munge(user.Name), user.Points)
+		}
+
+		blocks = leaderboardBlocks(l, p.Heading, url, leaderboard, offset)
+	}
+
+	var buttons []slack.BlockElement
+	if p.Page > 0 {
+		button, err := p.button(actionPreviousPage, l.Sprintf("Previous"), p.Page-1)
+		if err != nil {
+			return "", nil, err
+		}
+		buttons = append(buttons, button)
+	}
+	if hasNext {
+		button, err := p.button(actionNextPage, l.Sprintf("Next"), p.Page+1)
+		if err != nil {
+			return "", nil, err
+		}
+		buttons = append(buttons, button)
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, slack.NewActionBlock("leaderboard_pagination", buttons...))
+	}
+
+	return text, blocks, nil
+}
+
+// button returns a button that leads to another page.
+func (p *leaderboardPage) button(actionID, label string, page int) (*slack.ButtonBlockElement, error) {
+	target := *p
+	target.Page = page
+
+	value, err := json.Marshal(&target)
+	if err != nil {
+		return nil, err
+	}
+
+	return slack.NewButtonBlockElement(actionID, string(value), slack.NewTextBlockObject(slack.PlainTextType, label, false, false)), nil
+}
+
+// handleInteraction handles clicks on the buttons in karmabot's
+// messages.
+func (b *Bot) handleInteraction(ctx context.Context, callback *slack.InteractionCallback) {
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		switch action.ActionID {
+		case actionPreviousPage, actionNextPage:
+			b.turnLeaderboardPage(ctx, callback, action)
+		}
+	}
+}
+
+// turnLeaderboardPage replaces a leaderboard message with the page
+// that a pagination button leads to.
+func (b *Bot) turnLeaderboardPage(ctx context.Context, callback *slack.InteractionCallback, action *slack.BlockAction) {
+	page := &leaderboardPage{}
+	err := json.Unmarshal([]byte(action.Value), page)
+	if b.handleError(err, nil) {
+		return
+	}
+
+	text, blocks, err := page.render(ctx, b.Config.DB, b.Config.UI, b.locale(callback.Container.ChannelID), b.templates().LeaderboardRow)
+	if b.handleError(err, nil) {
+		return
+	}
+
+	// ephemeral messages can only be replaced through the
+	// interaction's response URL
+	if callback.Container.IsEphemeral {
+		msg := &slack.WebhookMessage{
+			Text:            text,
+			ReplaceOriginal: true,
+		}
+		if len(blocks) <= maxBlocks {
+			msg.Blocks = &slack.Blocks{BlockSet: blocks}
+		}
+
+		err = b.Config.Slack.Respond(callback.ResponseURL, msg)
+	} else {
+		err = b.Config.Slack.UpdateMessage(callback.Container.ChannelID, callback.Container.MessageTs, text, blockOptions(blocks)...)
+	}
+	if err != nil {
+		b.Config.Log.Err(err).Error("failed to update leaderboard")
+	}
+}
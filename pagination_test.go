@@ -0,0 +1,141 @@
+package karmabot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// pageButtons returns the values of the pagination buttons in a
+// message's blocks, keyed by action ID.
+func pageButtons(t *testing.T, msg *TestMessage) map[string]string {
+	var blocks slack.Blocks
+	err := json.Unmarshal([]byte(msg.Blocks), &blocks)
+	if err != nil {
+		t.Fatalf("could not decode blocks %s: %v", msg.Blocks, err)
+	}
+
+	buttons := make(map[string]string)
+	for _, block := range blocks.BlockSet {
+		if actions, ok := block.(*slack.ActionBlock); ok {
+			for _, element := range actions.Elements.ElementSet {
+				button := element.(*slack.ButtonBlockElement)
+				buttons[button.ActionID] = button.Value
+			}
+		}
+	}
+
+	return buttons
+}
+
+func TestLeaderboardPagination(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10})
+	for i := 1; i <= 24; i++ {
+		db.InsertPoints(context.Background(), &database.Points{From: "someone", To: fmt.Sprintf("user%02d", i), Points: i})
+	}
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma top 25",
+		Channel: "channel",
+	})
+
+	if len(cs.SentMessages) != 1 {
+		t.Fatalf("sent messages %+v; want 1 message", cs.SentMessages)
+	}
+	msg := cs.SentMessages[0]
+	if lines := strings.Count(msg.Text, "\n"); lines != 11 {
+		t.Errorf("sent leaderboard %q with %d lines; want a heading and 10 users", msg.Text, lines)
+	}
+
+	buttons := pageButtons(t, msg)
+	if _, ok := buttons[actionPreviousPage]; ok || buttons[actionNextPage] == "" {
+		t.Fatalf("first page has buttons %v; want only a next button", buttons)
+	}
+
+	for i, want := range []struct {
+		first          string
+		previous, next bool
+	}{
+		{"11. üser15 == 15\n", true, true},
+		{"21. üser05 == 5\n", true, false},
+	} {
+		b.handleInteraction(context.Background(), &slack.InteractionCallback{
+			Type:      slack.InteractionTypeBlockActions,
+			Container: slack.Container{ChannelID: "channel", MessageTs: "1234.5678"},
+			ActionCallback: slack.ActionCallbacks{
+				BlockActions: []*slack.BlockAction{{ActionID: actionNextPage, Value: buttons[actionNextPage]}},
+			},
+		})
+
+		if len(cs.UpdatedMessages) != i+1 {
+			t.Fatalf("updated messages %+v; want %d updates", cs.UpdatedMessages, i+1)
+		}
+		msg := cs.UpdatedMessages[i]
+		if msg.Channel != "channel" || !strings.HasPrefix(msg.Text, "*top 25 leaderboard*\n"+want.first) {
+			t.Errorf("updated message %+v; want page %d of the leaderboard", msg, i+2)
+		}
+
+		buttons = pageButtons(t, msg)
+		_, previous := buttons[actionPreviousPage]
+		_, next := buttons[actionNextPage]
+		if previous != want.previous || next != want.next {
+			t.Errorf("page %d has buttons %v; want previous %t and next %t", i+2, buttons, want.previous, want.next)
+		}
+	}
+}
+
+// tokenUI is a web UI whose URLs carry a new token every time.
+type tokenUI struct {
+	tokens int
+}
+
+func (u *tokenUI) Listen() error {
+	return nil
+}
+
+func (u *tokenUI) GetURL(uri string) (string, error) {
+	u.tokens++
+	return fmt.Sprintf("https://karma.example.com%s?token=%d", uri, u.tokens), nil
+}
+
+func TestLeaderboardPaginationURL(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: &tokenUI{}, LeaderboardLimit: 10})
+	for i := 1; i <= 14; i++ {
+		db.InsertPoints(context.Background(), &database.Points{From: "someone", To: fmt.Sprintf("user%02d", i), Points: i})
+	}
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma top 15",
+		Channel: "channel",
+	})
+
+	if len(cs.SentMessages) != 1 {
+		t.Fatalf("sent messages %+v; want 1 message", cs.SentMessages)
+	}
+	next := pageButtons(t, cs.SentMessages[0])[actionNextPage]
+	if strings.Contains(next, "token") {
+		t.Errorf("next button has value %s; want no token in it", next)
+	}
+
+	b.handleInteraction(context.Background(), &slack.InteractionCallback{
+		Type:      slack.InteractionTypeBlockActions,
+		Container: slack.Container{ChannelID: "channel", MessageTs: "1234.5678"},
+		ActionCallback: slack.ActionCallbacks{
+			BlockActions: []*slack.BlockAction{{ActionID: actionNextPage, Value: next}},
+		},
+	})
+
+	want := "*top 15 leaderboard*\nhttps://karma.example.com/leaderboard/15?token=2\n"
+	if len(cs.UpdatedMessages) != 1 || !strings.HasPrefix(cs.UpdatedMessages[0].Text, want) {
+		t.Errorf("updated messages %+v; want the second page to start with %q", cs.UpdatedMessages, want)
+	}
+}
@@ -48,6 +48,36 @@ func (r *karmaRegex) GetGive() *regexp.Regexp {
 	return regexp.MustCompile(expression)
 }
 
+// GetOperation matches a single karma operation. Unlike GetGive,
+// it is not anchored to the end of the message and does not match
+// the reason, so that it can be used to find every operation in a
+// message.
+func (r *karmaRegex) GetOperation() *regexp.Regexp {
+	expression := fmt.Sprintf(
+		"(?:%s)|(?:%s)",
+		strings.Join(
+			[]string{
+				"^",
+				r.user,
+				r.autocomplete,
+				r.points,
+			},
+			"",
+		),
+		strings.Join(
+			[]string{
+				`\s+`,
+				r.user,
+				r.explicitAutocomplete,
+				r.points,
+			},
+			"",
+		),
+	)
+
+	return regexp.MustCompile(expression)
+}
+
 func (r *karmaRegex) GetMotivate() *regexp.Regexp {
 	expression := strings.Join(
 		[]string{
@@ -69,6 +99,7 @@ func (r *karmaRegex) GetQuery() *regexp.Regexp {
 			r.user,
 			r.autocomplete,
 			"==",
+			"(?: (raw))?",
 			"$",
 		},
 		"",
@@ -77,10 +108,12 @@ func (r *karmaRegex) GetQuery() *regexp.Regexp {
 	return regexp.MustCompile(expression)
 }
 
-func (r *karmaRegex) GetThrowback() *regexp.Regexp {
+func (r *karmaRegex) GetThrowback(trigger string) *regexp.Regexp {
 	expression := strings.Join(
 		[]string{
-			`^karma(?:bot)? (?:throwback) ?(`,
+			`^`,
+			trigger,
+			` (?:throwback) ?(`,
 			r.user,
 			r.autocomplete,
 			`)?$`,
@@ -90,3 +123,83 @@ func (r *karmaRegex) GetThrowback() *regexp.Regexp {
 
 	return regexp.MustCompile(expression)
 }
+
+func (r *karmaRegex) GetHistory(trigger string) *regexp.Regexp {
+	expression := strings.Join(
+		[]string{
+			`^`,
+			trigger,
+			` history(?: (`,
+			r.user,
+			r.autocomplete,
+			`))??(?: ([0-9]+))?$`,
+		},
+		"",
+	)
+
+	return regexp.MustCompile(expression)
+}
+
+func (r *karmaRegex) GetReasons(trigger string) *regexp.Regexp {
+	expression := strings.Join(
+		[]string{
+			`^`,
+			trigger,
+			` reasons(?: (`,
+			r.user,
+			r.autocomplete,
+			`))??(?: ([0-9]+))?$`,
+		},
+		"",
+	)
+
+	return regexp.MustCompile(expression)
+}
+
+// defaultTriggers are the words that karmabot commands start with
+// unless others are configured.
+var defaultTriggers = []string{"karma", "karmabot"}
+
+// GetTrigger returns an expression that matches any of the trigger
+// words, as well as a mention of the bot if botUserID is set.
+func (r *karmaRegex) GetTrigger(triggers []string, botUserID string) string {
+	var alternatives []string
+	for _, trigger := range triggers {
+		alternatives = append(alternatives, regexp.QuoteMeta(trigger))
+	}
+	if botUserID != "" {
+		alternatives = append(alternatives, fmt.Sprintf("<@%s>:?", regexp.QuoteMeta(botUserID)))
+	}
+
+	return fmt.Sprintf("(?:%s)", strings.Join(alternatives, "|"))
+}
+
+// botRegexps are the regular expressions that a bot matches
+// messages against.
+type botRegexps struct {
+	Motivate, GiveKarma, Operation, QueryKarma, Leaderboard, Givers, URL, SlackUser, Throwback, History, Reasons, Undo, Budget, Help *regexp.Regexp
+}
+
+// newBotRegexps builds the regular expressions of a bot whose
+// commands start with any of the trigger words, or with a mention of
+// the bot if botUserID is set.
+func newBotRegexps(triggers []string, botUserID string) *botRegexps {
+	trigger := karmaReg.GetTrigger(triggers, botUserID)
+
+	return &botRegexps{
+		Motivate:    karmaReg.GetMotivate(),
+		GiveKarma:   karmaReg.GetGive(),
+		Operation:   karmaReg.GetOperation(),
+		QueryKarma:  karmaReg.GetQuery(),
+		Leaderboard: regexp.MustCompile(`^` + trigger + ` (?:leaderboard|top|highscores) ?([0-9]+)? ?(things|people)? ?(here)? ?((?:this|last) (?:day|week|month|year)|today|since [0-9]{4}-[0-9]{2}-[0-9]{2})? ?(raw)?$`),
+		Givers:      regexp.MustCompile(`^` + trigger + ` givers ?([0-9]+)?$`),
+		URL:         regexp.MustCompile(`^` + trigger + ` (?:url|web|link)?$`),
+		SlackUser:   regexp.MustCompile(`^<@([A-Za-z0-9]+)>$`),
+		Throwback:   karmaReg.GetThrowback(trigger),
+		History:     karmaReg.GetHistory(trigger),
+		Reasons:     karmaReg.GetReasons(trigger),
+		Undo:        regexp.MustCompile(`^` + trigger + ` undo$`),
+		Budget:      regexp.MustCompile(`^` + trigger + ` budget$`),
+		Help:        regexp.MustCompile(`^` + trigger + ` help(?: (\S+))?$`),
+	}
+}
@@ -11,6 +11,8 @@ type regexPattern struct {
 	Name  string
 }
 
+var testRegexps = newBotRegexps(defaultTriggers, "")
+
 var regexTests = map[regexPattern]regexTestSuite{
 	regexPattern{
 		Regex: karmaReg.GetGive(),
@@ -47,9 +49,11 @@ var regexTests = map[regexPattern]regexTestSuite{
 			"user==",
 			"@user==",
 			"<@U1384>==",
+			"user== raw",
 		},
 		false: []string{
 			"user=",
+			"user==raw",
 			"user===",
 			"@user=",
 			"@user===",
@@ -73,7 +77,7 @@ var regexTests = map[regexPattern]regexTestSuite{
 		},
 	},
 	regexPattern{
-		Regex: regexps.Leaderboard,
+		Regex: testRegexps.Leaderboard,
 		Name:  "leaderboard",
 	}: regexTestSuite{
 		true: []string{
@@ -81,14 +85,40 @@ var regexTests = map[regexPattern]regexTestSuite{
 			"karmabot top 10",
 			"karmabot top 1001",
 			"karmabot top ",
+			"karma top 10 this week",
+			"karma top last month",
+			"karma top today",
+			"karmabot leaderboard 5 since 2026-01-01",
+			"karma top things",
+			"karma top 5 people this week",
+			"karma top raw",
+			"karma top here",
+			"karma top 5 people here this week raw",
+			"karma top 5 things last month raw",
 		},
 		false: []string{
 			"karmabot top 913f",
 			"karmabot karma highscores",
+			"karma top 10 next week",
+			"karma top since yesterday",
+			"karma top stuff",
 		},
 	},
 	regexPattern{
-		Regex: regexps.SlackUser,
+		Regex: testRegexps.Givers,
+		Name:  "givers",
+	}: regexTestSuite{
+		true: []string{
+			"karma givers",
+			"karmabot givers 5",
+		},
+		false: []string{
+			"karma givers five",
+			"karma giver",
+		},
+	},
+	regexPattern{
+		Regex: testRegexps.SlackUser,
 		Name:  "slack user",
 	}: regexTestSuite{
 		true: []string{
@@ -104,7 +134,7 @@ var regexTests = map[regexPattern]regexTestSuite{
 		},
 	},
 	regexPattern{
-		Regex: regexps.URL,
+		Regex: testRegexps.URL,
 		Name:  "karmabot web ui",
 	}: regexTestSuite{
 		true: []string{
@@ -117,7 +147,7 @@ var regexTests = map[regexPattern]regexTestSuite{
 		},
 	},
 	regexPattern{
-		Regex: regexps.Throwback,
+		Regex: testRegexps.Throwback,
 		Name:  "karmabot throwback",
 	}: regexTestSuite{
 		true: []string{
@@ -128,6 +158,68 @@ var regexTests = map[regexPattern]regexTestSuite{
 			"karmabot throwback",
 		},
 	},
+	regexPattern{
+		Regex: testRegexps.History,
+		Name:  "karmabot history",
+	}: regexTestSuite{
+		true: []string{
+			"karma history",
+			"karma history 5",
+			"karma history <@U3494519>",
+			"karmabot history @name 20",
+			"karmabot history user",
+		},
+		false: []string{
+			"karma history user 5 6",
+			"karma histories",
+		},
+	},
+	regexPattern{
+		Regex: testRegexps.Reasons,
+		Name:  "karmabot reasons",
+	}: regexTestSuite{
+		true: []string{
+			"karma reasons",
+			"karma reasons 3",
+			"karmabot reasons <@U3494519> 10",
+			"karma reasons user",
+		},
+		false: []string{
+			"karma reasons user 5 6",
+			"karma reason user",
+		},
+	},
+	regexPattern{
+		Regex: newBotRegexps([]string{"kb", "c++"}, "U1234").Leaderboard,
+		Name:  "custom triggers",
+	}: regexTestSuite{
+		true: []string{
+			"kb top",
+			"c++ top 5",
+			"<@U1234> top",
+			"<@U1234>: top this week",
+		},
+		false: []string{
+			"karma top",
+			"karmabot top",
+			"c+ top",
+			"<@U5678> top",
+		},
+	},
+	regexPattern{
+		Regex: testRegexps.Help,
+		Name:  "karmabot help",
+	}: regexTestSuite{
+		true: []string{
+			"karma help",
+			"karmabot help top",
+			"karma help ++",
+		},
+		false: []string{
+			"karma help top 5",
+			"karma helps",
+		},
+	},
 }
 
 func TestRegexes(t *testing.T) {
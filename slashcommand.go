@@ -0,0 +1,99 @@
+package karmabot
+
+import (
+	"context"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// commandVerbs are the verbs that slash commands share with
+// messages that start with a trigger word such as `karma`.
+var commandVerbs = map[string]bool{
+	"leaderboard": true,
+	"top":         true,
+	"highscores":  true,
+	"givers":      true,
+	"throwback":   true,
+	"history":     true,
+	"reasons":     true,
+	"undo":        true,
+	"budget":      true,
+	"help":        true,
+	"url":         true,
+	"web":         true,
+	"link":        true,
+}
+
+// commandUsage is sent in response to slash commands that
+// karmabot does not understand.
+const commandUsage = "usage: `/karma <user>++ [for <reason>]`, `/karma <user>`, `/karma top [n]`, `/karma throwback [user]` or `/karma url`. send `/karma help` to list all commands"
+
+// commandText translates the text of a slash command into the
+// message that runs the same karmabot command. It returns an empty
+// string if the slash command is not a karmabot command.
+func (b *Bot) commandText(text string) string {
+	text = strings.TrimSpace(text)
+
+	fields := strings.Fields(text)
+	switch {
+	case len(fields) == 0:
+		return ""
+	case commandVerbs[fields[0]]:
+		return b.trigger + " " + text
+	case b.regexps.GiveKarma.MatchString(text), b.regexps.QueryKarma.MatchString(text):
+		return text
+	case len(fields) == 1:
+		// `/karma <user>` queries the user's points
+		return text + "=="
+	case len(fields) == 2 && fields[1] == "raw":
+		return fields[0] + "== raw"
+	default:
+		return ""
+	}
+}
+
+// handleSlashCommand runs a slash command as if its text had been
+// sent as a message. Replies are sent to the command's response URL
+// so that the command works in channels that karmabot has not been
+// invited to.
+func (b *Bot) handleSlashCommand(ctx context.Context, cmd *slack.SlashCommand) {
+	ev := &slackevents.MessageEvent{
+		Type:    "message",
+		User:    cmd.UserID,
+		Channel: cmd.ChannelID,
+		Text:    b.commandText(cmd.Text),
+	}
+
+	b.commands.Store(ev, cmd.ResponseURL)
+	defer b.commands.Delete(ev)
+
+	if ev.Text == "" {
+		b.SendReplyEphemeral(b.locale(ev.Channel).Sprintf(commandUsage), ev)
+		return
+	}
+
+	b.handleMessageEvent(ctx, ev)
+}
+
+// respond replies to a slash command through its response URL.
+// Replies are only visible to the user who ran the command unless
+// public is set.
+func (b *Bot) respond(reply, responseURL string, public bool, blocks ...slack.Block) {
+	msg := &slack.WebhookMessage{
+		Text:         reply,
+		ResponseType: slack.ResponseTypeEphemeral,
+	}
+	if public {
+		msg.ResponseType = slack.ResponseTypeInChannel
+	}
+	if len(blocks) > 0 && len(blocks) <= maxBlocks {
+		msg.Blocks = &slack.Blocks{BlockSet: blocks}
+	}
+
+	err := b.Config.Slack.Respond(responseURL, msg)
+	if err != nil {
+		b.Config.Log.Err(err).Error("failed to respond to slash command")
+	}
+}
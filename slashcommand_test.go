@@ -0,0 +1,63 @@
+package karmabot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack"
+)
+
+func TestCommandText(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"alice++ for the launch":    "alice++ for the launch",
+		"alice":                     "alice==",
+		"alice==":                   "alice==",
+		"alice raw":                 "alice== raw",
+		"top 5":                     "karma top 5",
+		"  throwback alice ":        "karma throwback alice",
+		"url":                       "karma url",
+		"what is the meaning of it": "",
+	}
+
+	b := NewBot(&Config{})
+	for text, want := range tests {
+		if got := b.commandText(text); got != want {
+			t.Errorf("commandText(%q) = %q; want %q", text, got, want)
+		}
+	}
+}
+
+func TestHandleSlashCommand(t *testing.T) {
+	b, cs, db := newBot(&Config{UI: blankui.New(), MaxPoints: 6, LeaderboardLimit: 10})
+
+	for _, text := range []string{"bob++", "bob", "top 1", "what is this"} {
+		b.handleSlashCommand(context.Background(), &slack.SlashCommand{
+			Command:     "/karma",
+			Text:        text,
+			UserID:      "U9876",
+			ChannelID:   "C1",
+			ResponseURL: "https://hooks.slack.test/commands/1",
+		})
+	}
+
+	if len(db.records) != 2 {
+		t.Errorf("stored %d records; want %d", len(db.records), 2)
+	}
+
+	want := []string{
+		"bob == 1 (+1)",
+		"bob == 1",
+		"*top 1 leaderboard*\n1. önehundred_points == 100\n",
+		commandUsage,
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent messages %+v; want %d messages", cs.SentMessages, len(want))
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Channel != "https://hooks.slack.test/commands/1" || msg.Text != want[i] {
+			t.Errorf("sent message %+v; want %q to the response URL", msg, want[i])
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package karmabot
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Templates customize karmabot's chat replies with text/template
+// strings. Replies whose template is nil are left as they are.
+// Customized points and throwback replies are sent as a single text
+// block instead of karmabot's own blocks, and customized leaderboard
+// rows replace the leaderboard's fields with a text block.
+type Templates struct {
+	// Points renders a PointsReply after every karma operation.
+	Points *template.Template
+
+	// LeaderboardHeader renders a LeaderboardHeader above
+	// leaderboards and LeaderboardRow renders a LeaderboardRow
+	// for every user on them.
+	LeaderboardHeader, LeaderboardRow *template.Template
+
+	// Throwback renders a ThrowbackReply.
+	Throwback *template.Template
+
+	// Query renders a QueryReply in response to `<user>==`.
+	Query *template.Template
+
+	// Error renders an ErrorReply when a command fails.
+	Error *template.Template
+}
+
+// A PointsReply describes a user's points after a karma operation.
+type PointsReply struct {
+	// Name is the name of the user who received the points.
+	Name string
+
+	// Points and Previous are the user's points after and before
+	// the operation, and Delta is the points that it gave or took.
+	// Points and Previous are never decayed.
+	Points, Previous, Delta int
+
+	// Rank is the user's place on the leaderboard.
+	Rank int
+
+	// Giver is the name of the user who gave the points.
+	Giver string
+
+	// Reason is the reason that was given, if any.
+	Reason string
+}
+
+// A LeaderboardHeader describes a leaderboard.
+type LeaderboardHeader struct {
+	// Limit is the amount of users on the leaderboard.
+	Limit int
+
+	// Title describes who is on the leaderboard, e.g.
+	// "leaderboard" or "people in #general".
+	Title string
+
+	// Period is the time range that the leaderboard counts
+	// karma for as it was typed, e.g. "this week", if any.
+	Period string
+}
+
+// A LeaderboardRow describes a user on a leaderboard.
+type LeaderboardRow struct {
+	Rank   int
+	Name   string
+	Points int
+}
+
+// A ThrowbackReply describes a random karma operation.
+type ThrowbackReply struct {
+	// Name is the name of the user who received the points and
+	// Giver is the name of the user who gave them.
+	Name, Giver string
+
+	Points int
+	Reason string
+
+	// Time is when the operation took place, relative to now,
+	// e.g. "3 days ago".
+	Time      string
+	Timestamp time.Time
+}
+
+// A QueryReply describes a user's points.
+type QueryReply struct {
+	Name   string
+	Points int
+	Rank   int
+}
+
+// An ErrorReply describes a failed command.
+type ErrorReply struct {
+	// Message is the error, or a generic message unless karmabot
+	// runs in debug mode.
+	Message string
+}
+
+// templateFields lists the templates that can be customized by their
+// name, along with example data that they are validated with.
+var templateFields = map[string]struct {
+	field   func(t *Templates) **template.Template
+	example interface{}
+}{
+	"points": {
+		func(t *Templates) **template.Template { return &t.Points },
+		&PointsReply{Name: "alice", Points: 3, Previous: 1, Delta: 2, Rank: 1, Giver: "bob", Reason: "the launch"},
+	},
+	"leaderboard_header": {
+		func(t *Templates) **template.Template { return &t.LeaderboardHeader },
+		&LeaderboardHeader{Limit: 10, Title: "leaderboard", Period: "this week"},
+	},
+	"leaderboard_row": {
+		func(t *Templates) **template.Template { return &t.LeaderboardRow },
+		&LeaderboardRow{Rank: 1, Name: "alice", Points: 3},
+	},
+	"throwback": {
+		func(t *Templates) **template.Template { return &t.Throwback },
+		&ThrowbackReply{Name: "alice", Giver: "bob", Points: 2, Reason: "the launch", Time: "3 days ago", Timestamp: time.Now()},
+	},
+	"query": {
+		func(t *Templates) **template.Template { return &t.Query },
+		&QueryReply{Name: "alice", Points: 3, Rank: 1},
+	},
+	"error": {
+		func(t *Templates) **template.Template { return &t.Error },
+		&ErrorReply{Message: "an error has occurred."},
+	},
+}
+
+// ParseTemplates parses templates keyed by their name: points,
+// leaderboard_header, leaderboard_row, throwback, query or error.
+// Every template is executed with example data so that mistakes such
+// as unknown fields are reported right away.
+func ParseTemplates(sources map[string]string) (*Templates, error) {
+	templates := new(Templates)
+	for name, source := range sources {
+		f, ok := templateFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown template %q. known templates are %s", name, strings.Join(templateNames(), ", "))
+		}
+
+		tmpl, err := template.New(name).Parse(source)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tmpl.Execute(ioutil.Discard, f.example)
+		if err != nil {
+			return nil, err
+		}
+
+		*f.field(templates) = tmpl
+	}
+
+	return templates, nil
+}
+
+// templateNames returns the names of the templates that can be
+// customized, sorted.
+func templateNames() []string {
+	var names []string
+	for name := range templateFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// execute renders a template to a string.
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// templates returns the bot's templates. The zero Templates keeps
+// every reply as it is.
+func (b *Bot) templates() *Templates {
+	if b.Config.Templates == nil {
+		return new(Templates)
+	}
+
+	return b.Config.Templates
+}
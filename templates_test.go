@@ -0,0 +1,102 @@
+package karmabot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aybabtme/log"
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestParseTemplates(t *testing.T) {
+	templates, err := ParseTemplates(map[string]string{
+		"points": "{{.Name}} {{.Previous}} -> {{.Points}}",
+		"error":  "oops: {{.Message}}",
+	})
+	if err != nil {
+		t.Fatalf("could not parse templates: %v", err)
+	}
+	if templates.Points == nil || templates.Error == nil || templates.Query != nil {
+		t.Errorf("parsed templates %+v; want only points and error", templates)
+	}
+
+	for name, sources := range map[string]map[string]string{
+		"unknown template": {"nope": "{{.Name}}"},
+		"unknown field":    {"query": "{{.Giver}}"},
+		"syntax error":     {"throwback": "{{.Name"},
+	} {
+		if _, err := ParseTemplates(sources); err == nil {
+			t.Errorf("parsed %s %v; want an error", name, sources)
+		}
+	}
+}
+
+func TestTemplateReplies(t *testing.T) {
+	templates, err := ParseTemplates(map[string]string{
+		"points":             "{{.Giver}} gave {{.Name}} {{.Delta}} ({{.Previous}} -> {{.Points}}, #{{.Rank}}) for {{.Reason}}",
+		"query":              "{{.Name}} has {{.Points}} points and is #{{.Rank}}",
+		"leaderboard_header": "best {{.Limit}} {{.Title}}",
+		"leaderboard_row":    "{{.Rank}}) {{.Name}}: {{.Points}}",
+		"error":              "oops: {{.Message}}",
+	})
+	if err != nil {
+		t.Fatalf("could not parse templates: %v", err)
+	}
+
+	b, cs, _ := newBot(&Config{UI: blankui.New(), LeaderboardLimit: 10, MaxPoints: 6, Templates: templates, Log: log.KV("test", t.Name())})
+	for _, text := range []string{"bob+++ for the launch", "bob==", "karma top 2"} {
+		b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+			Type:    "message",
+			Text:    text,
+			Channel: "channel",
+			User:    "U9876",
+		})
+	}
+	b.handleError(errors.New("database is down"), &slackevents.MessageEvent{Channel: "channel"})
+
+	want := []string{
+		"U9876 gave bob 2 (0 -> 2, #2) for the launch",
+		"bob has 2 points and is #2",
+		"best 2 leaderboard\n1) önehundred_points: 100\n2) Бob: 2\n",
+		"oops: an error has occurred.",
+	}
+	if len(cs.SentMessages) != len(want) {
+		t.Fatalf("sent messages %+v; want %d messages", cs.SentMessages, len(want))
+	}
+	for i, msg := range cs.SentMessages {
+		if msg.Text != want[i] {
+			t.Errorf("sent message %q; want %q", msg.Text, want[i])
+		}
+	}
+
+	if !strings.Contains(cs.SentMessages[2].Blocks, `"text":"1) önehundred_points: 100\n2) Бob: 2\n"`) {
+		t.Errorf("sent leaderboard with blocks %s; want the rendered rows", cs.SentMessages[2].Blocks)
+	}
+}
+
+func TestPointsTemplateDecay(t *testing.T) {
+	templates, err := ParseTemplates(map[string]string{
+		"points": "{{.Previous}} -> {{.Points}}",
+	})
+	if err != nil {
+		t.Fatalf("could not parse templates: %v", err)
+	}
+
+	b, cs, _ := newBotAt(&Config{UI: blankui.New(), LeaderboardLimit: 10, MaxPoints: 6, HalfLife: 24 * time.Hour, Templates: templates, Log: log.KV("test", t.Name())}, time.Now().Add(-48*time.Hour))
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "onehundred_points++",
+		Channel: "channel",
+		User:    "U9876",
+	})
+
+	want := "100 -> 101"
+	if len(cs.SentMessages) != 1 || cs.SentMessages[0].Text != want {
+		t.Errorf("sent messages %+v; want %q", cs.SentMessages, want)
+	}
+}
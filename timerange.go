@@ -0,0 +1,100 @@
+package karmabot
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kamaln7/karmabot/database"
+)
+
+// dateFormat is the format of dates in commands and web UI links.
+const dateFormat = "2006-01-02"
+
+// parseTimeRange converts a time range such as "today", "this week",
+// "last month" or "since 2026-01-01" to a filter, relative to now.
+// Weeks start on Monday. An empty range returns a nil filter.
+func parseTimeRange(text string, now time.Time) (*database.Filter, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(text, "since ") {
+		since, err := time.ParseInLocation(dateFormat, strings.TrimPrefix(text, "since "), now.Location())
+		if err != nil {
+			return nil, err
+		}
+
+		return &database.Filter{Since: since}, nil
+	}
+
+	if text == "today" {
+		text = "this day"
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid time range %q", text)
+	}
+
+	var (
+		today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		start time.Time
+		prev  func(time.Time) time.Time
+	)
+	switch fields[1] {
+	case "day":
+		start = today
+		prev = func(t time.Time) time.Time { return t.AddDate(0, 0, -1) }
+	case "week":
+		start = today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		prev = func(t time.Time) time.Time { return t.AddDate(0, 0, -7) }
+	case "month":
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		prev = func(t time.Time) time.Time { return t.AddDate(0, -1, 0) }
+	case "year":
+		start = time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+		prev = func(t time.Time) time.Time { return t.AddDate(-1, 0, 0) }
+	default:
+		return nil, fmt.Errorf("invalid time range %q", text)
+	}
+
+	switch fields[0] {
+	case "this":
+		return &database.Filter{Since: start}, nil
+	case "last":
+		return &database.Filter{Since: prev(start), Until: start}, nil
+	default:
+		return nil, fmt.Errorf("invalid time range %q", text)
+	}
+}
+
+// filterQuery returns the web UI query string for a filter.
+// The web UI's `to` date is inclusive, and the web UI does
+// not decay points. The web UI looks channels up by name, so
+// channel is the name of the filter's channel, if any.
+func filterQuery(filter *database.Filter, channel string) string {
+	if filter.IsZero() {
+		return ""
+	}
+
+	query := url.Values{}
+	if !filter.Since.IsZero() {
+		query.Set("from", filter.Since.Format(dateFormat))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("to", filter.Until.AddDate(0, 0, -1).Format(dateFormat))
+	}
+	if filter.Kind != "" {
+		query.Set("kind", filter.Kind)
+	}
+	if channel != "" {
+		query.Set("channel", channel)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
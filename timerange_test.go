@@ -0,0 +1,69 @@
+package karmabot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot/ui/blankui"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2026, time.March, 18, 15, 30, 0, 0, time.UTC)
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tt := []struct {
+		Text         string
+		Since, Until time.Time
+	}{
+		{Text: "today", Since: date(2026, time.March, 18)},
+		{Text: "last day", Since: date(2026, time.March, 17), Until: date(2026, time.March, 18)},
+		{Text: "this week", Since: date(2026, time.March, 16)},
+		{Text: "last week", Since: date(2026, time.March, 9), Until: date(2026, time.March, 16)},
+		{Text: "this month", Since: date(2026, time.March, 1)},
+		{Text: "last month", Since: date(2026, time.February, 1), Until: date(2026, time.March, 1)},
+		{Text: "last year", Since: date(2025, time.January, 1), Until: date(2026, time.January, 1)},
+		{Text: "since 2026-01-05", Since: date(2026, time.January, 5)},
+	}
+
+	for _, tc := range tt {
+		filter, err := parseTimeRange(tc.Text, now)
+		if err != nil {
+			t.Errorf("parseTimeRange(%q): %v", tc.Text, err)
+			continue
+		}
+
+		if !filter.Since.Equal(tc.Since) || !filter.Until.Equal(tc.Until) {
+			t.Errorf("parseTimeRange(%q) = [%v, %v); want [%v, %v)", tc.Text, filter.Since, filter.Until, tc.Since, tc.Until)
+		}
+	}
+
+	if filter, err := parseTimeRange("", now); filter != nil || err != nil {
+		t.Errorf("parseTimeRange(%q) = %v, %v; want nil, nil", "", filter, err)
+	}
+
+	if _, err := parseTimeRange("next week", now); err == nil {
+		t.Errorf("parseTimeRange(%q): expected an error", "next week")
+	}
+}
+
+func TestPrintLeaderboardTimeRange(t *testing.T) {
+	b, cs, db := newBotAt(&Config{UI: blankui.New(), LeaderboardLimit: 10}, time.Now().AddDate(-2, 0, 0))
+	db.InsertPoints(context.Background(), &database.Points{From: "point_giver", To: "recent", Points: 3})
+
+	b.handleMessageEvent(context.Background(), &slackevents.MessageEvent{
+		Type:    "message",
+		Text:    "karma top 5 this year",
+		Channel: "channel",
+	})
+
+	want := "*top 5 leaderboard this year*\n1. ŗecent == 3\n"
+	if len(cs.SentMessages) != 1 || cs.SentMessages[0].Text != want {
+		t.Errorf("sent messages %+v; want %q", cs.SentMessages, want)
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kamaln7/karmabot/database"
 
 	"github.com/gorilla/mux"
 )
 
+// dateFormat is the format of the `from` and `to`
+// query parameters.
+const dateFormat = "2006-01-02"
+
 // Handlers contains all the http.HandlerFuncs
 // that serve the web UI's routes.
 type Handlers struct {
@@ -41,9 +47,54 @@ func (h *Handlers) Leaderboard(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	points, err := h.ui.Config.DB.GetTotalPoints()
+	var (
+		filter   = &database.Filter{}
+		from, to = r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		kind     = r.URL.Query().Get("kind")
+		channel  = strings.TrimPrefix(r.URL.Query().Get("channel"), "#")
+	)
+	switch kind {
+	case "", database.KindUser, database.KindThing:
+		filter.Kind = kind
+	default:
+		h.ui.renderError(w, fmt.Errorf("invalid kind %q", kind))
+		return
+	}
+	if from != "" {
+		filter.Since, err = time.ParseInLocation(dateFormat, from, time.Local)
+		if err != nil {
+			h.ui.renderError(w, err)
+			return
+		}
+	}
+	if to != "" {
+		filter.Until, err = time.ParseInLocation(dateFormat, to, time.Local)
+		if err != nil {
+			h.ui.renderError(w, err)
+			return
+		}
+
+		// the `to` date is inclusive
+		filter.Until = filter.Until.AddDate(0, 0, 1)
+	}
+
+	if channel != "" {
+		filter.Channel, err = h.ui.Config.DB.GetChannelID(r.Context(), channel)
+		if err != nil {
+			h.ui.renderError(w, err)
+			return
+		}
+	}
 
-	leaderboard, err := h.ui.Config.DB.GetLeaderboard(limit)
+	points, err := h.ui.Config.DB.GetTotalPoints(r.Context(), filter)
+	if err != nil {
+		h.ui.Config.Log.Err(err).Error("could not count total points")
+
+		h.ui.renderError(w, err)
+		return
+	}
+
+	leaderboard, err := h.ui.Config.DB.GetLeaderboard(r.Context(), limit, filter)
 	if err != nil {
 		h.ui.Config.Log.Err(err).KV("limit", limit).Error("could not generate leaderboard")
 
@@ -56,11 +107,16 @@ func (h *Handlers) Leaderboard(w http.ResponseWriter, r *http.Request) {
 			LeaderboardLimit: h.ui.Config.LeaderboardLimit,
 		},
 		Data: &struct {
-			Limit, TotalPoints int
-			Leaderboard        database.Leaderboard
+			Limit, TotalPoints      int
+			From, To, Kind, Channel string
+			Leaderboard             database.Leaderboard
 		}{
 			Limit:       limit,
 			TotalPoints: points,
+			From:        from,
+			To:          to,
+			Kind:        kind,
+			Channel:     channel,
 			Leaderboard: leaderboard,
 		},
 	}
@@ -68,6 +124,111 @@ func (h *Handlers) Leaderboard(w http.ResponseWriter, r *http.Request) {
 	h.ui.renderTemplate(w, "leaderboard.html", data)
 }
 
+// Givers serves the givers leaderboard view.
+func (h *Handlers) Givers(w http.ResponseWriter, r *http.Request) {
+	var (
+		limit int
+		err   error
+	)
+
+	limitS := mux.Vars(r)["limit"]
+
+	if limitS == "" {
+		limit = h.ui.Config.LeaderboardLimit
+	} else {
+		limit, err = strconv.Atoi(limitS)
+
+		if err != nil {
+			h.ui.renderError(w, err)
+			return
+		}
+	}
+
+	givers, err := h.ui.Config.DB.GetGivers(r.Context(), limit)
+	if err != nil {
+		h.ui.Config.Log.Err(err).KV("limit", limit).Error("could not generate givers leaderboard")
+
+		h.ui.renderError(w, err)
+		return
+	}
+
+	data := &templateData{
+		Config: &templateConfig{
+			LeaderboardLimit: h.ui.Config.LeaderboardLimit,
+		},
+		Data: &struct {
+			Limit  int
+			Givers database.Givers
+		}{
+			Limit:  limit,
+			Givers: givers,
+		},
+	}
+
+	h.ui.renderTemplate(w, "givers.html", data)
+}
+
+// Reasons serves the view of the reasons for which a user has
+// received karma most often.
+func (h *Handlers) Reasons(w http.ResponseWriter, r *http.Request) {
+	var (
+		vars  = mux.Vars(r)
+		name  = vars["user"]
+		limit int
+		err   error
+	)
+
+	limitS := vars["limit"]
+
+	if limitS == "" {
+		limit = h.ui.Config.LeaderboardLimit
+	} else {
+		limit, err = strconv.Atoi(limitS)
+
+		if err != nil {
+			h.ui.renderError(w, err)
+			return
+		}
+	}
+
+	// Slack users are stored by their ID and things by their
+	// lowercased name
+	user, err := h.ui.Config.DB.GetUserID(r.Context(), name)
+	switch err {
+	case nil:
+	case database.ErrNoSuchUser:
+		user = strings.ToLower(name)
+	default:
+		h.ui.renderError(w, err)
+		return
+	}
+
+	reasons, err := h.ui.Config.DB.GetReasons(r.Context(), user, limit)
+	if err != nil {
+		h.ui.Config.Log.Err(err).KV("user", name).KV("limit", limit).Error("could not list reasons")
+
+		h.ui.renderError(w, err)
+		return
+	}
+
+	data := &templateData{
+		Config: &templateConfig{
+			LeaderboardLimit: h.ui.Config.LeaderboardLimit,
+		},
+		Data: &struct {
+			Limit   int
+			Name    string
+			Reasons database.Reasons
+		}{
+			Limit:   limit,
+			Name:    name,
+			Reasons: reasons,
+		},
+	}
+
+	h.ui.renderTemplate(w, "reasons.html", data)
+}
+
 // NotFound handles invalid URIs that do not
 // have a matching route.
 func (h *Handlers) NotFound(w http.ResponseWriter, r *http.Request) {
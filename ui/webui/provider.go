@@ -2,8 +2,9 @@ package webui
 
 import (
 	"fmt"
+	"strings"
 
-	"github.com/kamaln7/karmabot/database"
+	"github.com/kamaln7/karmabot"
 	"github.com/kamaln7/karmabot/ui"
 
 	"github.com/aybabtme/log"
@@ -17,7 +18,7 @@ type Config struct {
 	LeaderboardLimit                 int
 	Log                              *log.Log
 	Debug                            bool
-	DB                               *database.DB
+	DB                               karmabot.Database
 }
 
 // A Provider provides a UI service that can be
@@ -76,5 +77,10 @@ func (p *Provider) GetURL(URI string) (string, error) {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s%s?token=%s", p.Config.URL, URI, token), nil
+	separator := "?"
+	if strings.Contains(URI, "?") {
+		separator = "&"
+	}
+
+	return fmt.Sprintf("%s%s%stoken=%s", p.Config.URL, URI, separator, token), nil
 }
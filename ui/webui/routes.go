@@ -24,6 +24,10 @@ func (u *UI) setupRoutes() {
 	r.HandleFunc("/", h.MustAuth(h.Home)).Methods("GET")
 	r.HandleFunc("/leaderboard", h.MustAuth(h.Leaderboard)).Methods("GET")
 	r.HandleFunc(`/leaderboard/{limit:\d+}`, h.MustAuth(h.Leaderboard)).Methods("GET")
+	r.HandleFunc("/givers", h.MustAuth(h.Givers)).Methods("GET")
+	r.HandleFunc(`/givers/{limit:\d+}`, h.MustAuth(h.Givers)).Methods("GET")
+	r.HandleFunc("/reasons/{user}", h.MustAuth(h.Reasons)).Methods("GET")
+	r.HandleFunc(`/reasons/{user}/{limit:\d+}`, h.MustAuth(h.Reasons)).Methods("GET")
 
 	// custom handlers
 	r.NotFoundHandler = http.HandlerFunc(h.NotFound)